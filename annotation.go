@@ -0,0 +1,69 @@
+package protocplugin
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Annotation links a byte range of the content of a generated file to the element of the model it is generated from.
+type Annotation struct {
+	Element  Element                                            // Element is the element the range is generated from.
+	Begin    int                                                // Begin is the offset of the first byte of the range.
+	End      int                                                // End is the offset just after the last byte of the range.
+	Semantic descriptorpb.GeneratedCodeInfo_Annotation_Semantic // Semantic describes the effect of the code in the range on the element.
+}
+
+// Annotate records that the byte range [begin, end) of the content is generated from the element.
+func (f *GeneratedFile) Annotate(e Element, begin, end int) {
+	f.Annotations = append(f.Annotations, &Annotation{Element: e, Begin: begin, End: end})
+}
+
+// AppendAnnotated appends s to the content and records that it is generated from the element.
+func (f *GeneratedFile) AppendAnnotated(e Element, s string) {
+	begin := len(f.Content)
+	f.Content += s
+	f.Annotate(e, begin, len(f.Content))
+}
+
+// generatedCodeInfo returns the generated code info of the annotations, or nil if there are no annotations.
+func (f *GeneratedFile) generatedCodeInfo() (*descriptorpb.GeneratedCodeInfo, error) {
+	if len(f.Annotations) == 0 {
+		return nil, nil
+	}
+	info := &descriptorpb.GeneratedCodeInfo{}
+	for _, a := range f.Annotations {
+		if a.Element == nil {
+			return nil, fmt.Errorf("%s: annotation of range [%d, %d) has no element", f.Name, a.Begin, a.End)
+		}
+		if a.Begin < 0 || a.Begin > a.End || a.End > len(f.Content) {
+			return nil, fmt.Errorf("%s: annotation of range [%d, %d) is out of the content of %d bytes", f.Name, a.Begin, a.End, len(f.Content))
+		}
+		loc := LocationOf(a.Element)
+		annotation := &descriptorpb.GeneratedCodeInfo_Annotation{
+			Path:       append([]int32{}, loc.Path...),
+			SourceFile: proto.String(loc.File),
+			Begin:      proto.Int32(int32(a.Begin)),
+			End:        proto.Int32(int32(a.End)),
+		}
+		if a.Semantic != descriptorpb.GeneratedCodeInfo_Annotation_NONE {
+			annotation.Semantic = a.Semantic.Enum()
+		}
+		info.Annotation = append(info.Annotation, annotation)
+	}
+	return info, nil
+}
+
+// metaFile returns the file containing the generated code info in the protobuf text format in the same way as protoc-gen-go.
+func metaFile(file *pluginpb.CodeGeneratorResponse_File) (*pluginpb.CodeGeneratorResponse_File, error) {
+	b, err := prototext.Marshal(file.GetGeneratedCodeInfo())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal generated code info of %s: %w", file.GetName(), err)
+	}
+	return &pluginpb.CodeGeneratorResponse_File{
+		Name:    proto.String(file.GetName() + ".meta"),
+		Content: proto.String(string(b)),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+func TestRun_Annotations(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		request := files["main.proto"].Messages[0]
+		out := &protocplugin.GeneratedFile{Name: "main.go", Content: "type "}
+		out.AppendAnnotated(request, "Request")
+		out.Content += " struct {\n\t"
+		begin := len(out.Content)
+		out.Content += "Shared *Shared\n}\n"
+		out.Annotations = append(out.Annotations, &protocplugin.Annotation{
+			Element:  request.Fields[0],
+			Begin:    begin,
+			End:      begin + len("Shared"),
+			Semantic: descriptorpb.GeneratedCodeInfo_Annotation_SET,
+		})
+		return []*protocplugin.GeneratedFile{out, {Name: "plain.txt"}}, nil
+	}
+	wantInfo := &descriptorpb.GeneratedCodeInfo{Annotation: []*descriptorpb.GeneratedCodeInfo_Annotation{
+		{Path: []int32{4, 0}, SourceFile: proto.String("main.proto"), Begin: proto.Int32(5), End: proto.Int32(12)},
+		{Path: []int32{4, 0, 2, 0}, SourceFile: proto.String("main.proto"), Begin: proto.Int32(23), End: proto.Int32(29), Semantic: descriptorpb.GeneratedCodeInfo_Annotation_SET.Enum()},
+	}}
+
+	t.Run("generated code info", func(t *testing.T) {
+		resp := runPlugin(t, handle, testFileDep, testFileMain)
+		require.Empty(t, resp.GetError())
+		require.Len(t, resp.File, 2)
+		assert.Equal(t, "main.go", resp.File[0].GetName())
+		assert.True(t, proto.Equal(wantInfo, resp.File[0].GetGeneratedCodeInfo()))
+		assert.Nil(t, resp.File[1].GetGeneratedCodeInfo())
+	})
+
+	t.Run("meta files", func(t *testing.T) {
+		params := struct{}{}
+		req := newRequest(t, "annotate_code", testFileDep, testFileMain)
+		resp := runRequest(t, req, handle, protocplugin.WithParameters(&params))
+		require.Empty(t, resp.GetError())
+		require.Len(t, resp.File, 3)
+		assert.Equal(t, "main.go.meta", resp.File[1].GetName())
+		gotInfo := &descriptorpb.GeneratedCodeInfo{}
+		require.NoError(t, prototext.Unmarshal([]byte(resp.File[1].GetContent()), gotInfo))
+		assert.True(t, proto.Equal(wantInfo, gotInfo))
+		assert.Equal(t, "plain.txt", resp.File[2].GetName())
+	})
+
+	t.Run("invalid annotate code", func(t *testing.T) {
+		resp := runRequest(t, newRequest(t, "annotate_code=yes", testFileDep, testFileMain), handle)
+		assert.Equal(t, `invalid parameter: invalid value "yes" for parameter "annotate_code": want true or false`, resp.GetError())
+		assert.Empty(t, resp.File)
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+			out := &protocplugin.GeneratedFile{Name: "main.go", Content: "package main\n"}
+			out.Annotate(files["main.proto"].Messages[0], 5, 100)
+			return []*protocplugin.GeneratedFile{out}, nil
+		}
+		resp := runPlugin(t, handle, testFileDep, testFileMain)
+		assert.Equal(t, "main.go: annotation of range [5, 100) is out of the content of 13 bytes", resp.GetError())
+		assert.Empty(t, resp.File)
+	})
+}
@@ -0,0 +1,176 @@
+package protocplugin
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// RunCommand executes the plugin handler as a standalone command without protoc.
+// args are the command-line arguments without the program name, which accept the following flags followed by the paths of the files to generate:
+//
+//	-descriptor_set_in=FILE  a FileDescriptorSet including the imports, e.g. produced by "protoc --include_imports -o FILE" or "buf build -o FILE"
+//	-parameter=PARAMETER     the parameter passed to the plugin in the same way as "protoc --xxx_opt=PARAMETER"
+//	-out=DIR                 the directory to write the generated files to (default: ".")
+//
+// The generated files are written to the output directory, and files inserted at insertion points are inserted into the files generated together or existing in the directory.
+// The handler is executed in the same way as Run, and the error of the response is returned as an error.
+// Messages such as the usage and the warnings are written to stderr.
+//
+// A plugin can support both protoc and the standalone command as follows:
+//
+//	if len(os.Args) > 1 {
+//		err = protocplugin.RunCommand(os.Args[1:], os.Stderr, handle)
+//	} else {
+//		err = protocplugin.Run(os.Stdin, os.Stdout, handle)
+//	}
+func RunCommand(args []string, stderr io.Writer, handle PluginHandler, opts ...Option) error {
+	flags := flag.NewFlagSet("plugin", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	descriptorSet := flags.String("descriptor_set_in", "", "a FileDescriptorSet including the imports of the files to generate")
+	parameter := flags.String("parameter", "", "the parameter passed to the plugin")
+	outDir := flags.String("out", ".", "the directory to write the generated files to")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: %s -descriptor_set_in=FILE [-parameter=PARAMETER] [-out=DIR] FILE_TO_GENERATE...\n", flags.Name())
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *descriptorSet == "" {
+		return fmt.Errorf("-descriptor_set_in is required")
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no files to generate")
+	}
+
+	b, err := os.ReadFile(*descriptorSet)
+	if err != nil {
+		return fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, fds); err != nil {
+		return fmt.Errorf("failed to unmarshal descriptor set: %w", err)
+	}
+
+	req, err := NewRequest(fds.GetFile(), flags.Args(), *parameter)
+	if err != nil {
+		return err
+	}
+
+	cfg := &config{warnings: stderr}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	resp, err := generate(context.Background(), req, func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error) {
+		return handle(req, files, registry, diags)
+	}, cfg)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return errors.New(resp.GetError())
+	}
+
+	return writeFiles(*outDir, resp.GetFile())
+}
+
+// NewRequest returns a request with the parameter to generate the files in fileToGenerate, which is built from the files such as those in a FileDescriptorSet.
+// The proto files of the request are sorted so that each file follows its dependencies as protoc does.
+// An error is returned if a file to generate or a dependency is not in the files.
+func NewRequest(protoFiles []*descriptorpb.FileDescriptorProto, fileToGenerate []string, parameter string) (*pluginpb.CodeGeneratorRequest, error) {
+	byName := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, fd := range protoFiles {
+		byName[fd.GetName()] = fd
+	}
+	for _, name := range fileToGenerate {
+		if byName[name] == nil {
+			return nil, fmt.Errorf("file to generate %q is not in the descriptor set", name)
+		}
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{FileToGenerate: fileToGenerate}
+	if parameter != "" {
+		req.Parameter = proto.String(parameter)
+	}
+	visiting, visited := map[string]bool{}, map[string]bool{}
+	var visit func(fd *descriptorpb.FileDescriptorProto) error
+	visit = func(fd *descriptorpb.FileDescriptorProto) error {
+		if visited[fd.GetName()] {
+			return nil
+		}
+		if visiting[fd.GetName()] {
+			return fmt.Errorf("file %q imports itself recursively", fd.GetName())
+		}
+		visiting[fd.GetName()] = true
+		for _, dep := range fd.GetDependency() {
+			depFile := byName[dep]
+			if depFile == nil {
+				return fmt.Errorf("file %q imports %q which is not in the descriptor set", fd.GetName(), dep)
+			}
+			if err := visit(depFile); err != nil {
+				return err
+			}
+		}
+		visited[fd.GetName()] = true
+		req.ProtoFile = append(req.ProtoFile, fd)
+		return nil
+	}
+	for _, fd := range protoFiles {
+		if err := visit(fd); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// writeFiles writes the files of the response to the directory, inserting the files with insertion points into the files generated before or existing in the directory.
+func writeFiles(dir string, files []*pluginpb.CodeGeneratorResponse_File) error {
+	names := []string{}
+	contents := map[string]string{}
+	for _, f := range files {
+		if !filepath.IsLocal(filepath.FromSlash(f.GetName())) {
+			return fmt.Errorf("generated file name %q must be a relative path within the output directory", f.GetName())
+		}
+		if f.GetInsertionPoint() == "" {
+			if _, ok := contents[f.GetName()]; !ok {
+				names = append(names, f.GetName())
+			}
+			contents[f.GetName()] = f.GetContent()
+			continue
+		}
+
+		content, ok := contents[f.GetName()]
+		if !ok {
+			b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.GetName())))
+			if err != nil {
+				return fmt.Errorf("failed to read file to insert into: %w", err)
+			}
+			content = string(b)
+			names = append(names, f.GetName())
+		}
+		content, err := ApplyInsertion(content, &GeneratedFile{Name: f.GetName(), Content: f.GetContent(), InsertionPoint: f.GetInsertionPoint()})
+		if err != nil {
+			return err
+		}
+		contents[f.GetName()] = content
+	}
+
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(contents[name]), 0o644); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+	return nil
+}
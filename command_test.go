@@ -0,0 +1,159 @@
+package protocplugin_test
+
+import (
+	"bytes"
+	"fmt"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeDescriptorSet writes a FileDescriptorSet of the files written in the protobuf text format into a temporary file and returns its path.
+func writeDescriptorSet(t *testing.T, files ...string) string {
+	t.Helper()
+	fds := &descriptorpb.FileDescriptorSet{}
+	for _, f := range files {
+		fd := &descriptorpb.FileDescriptorProto{}
+		require.NoError(t, prototext.Unmarshal([]byte(f), fd))
+		fds.File = append(fds.File, fd)
+	}
+	b, err := proto.Marshal(fds)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "descriptor_set.binpb")
+	require.NoError(t, os.WriteFile(path, b, 0o644))
+	return path
+}
+
+// listFiles returns the names of the files generated by the handler.
+func listFiles(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+	content := strings.Join(req.GetFileToGenerate(), "\n") + "\n" + protocplugin.InsertionPointMarker("files.txt", "end") + "\n"
+	return []*protocplugin.GeneratedFile{
+		{Name: "out/files.txt", Content: content},
+		{Name: "out/files.txt", Content: "parameter=" + req.GetParameter(), InsertionPoint: "end"},
+	}, nil
+}
+
+func TestRunCommand(t *testing.T) {
+	descriptorSet := writeDescriptorSet(t, testFileMain, testFileDep)
+	outDir := t.TempDir()
+
+	stderr := bytes.Buffer{}
+	err := protocplugin.RunCommand([]string{"-descriptor_set_in", descriptorSet, "-parameter", "a=b", "-out", outDir, "main.proto", "dep.proto"}, &stderr, listFiles)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(filepath.Join(outDir, "out", "files.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "main.proto\ndep.proto\nparameter=a=b\n// @@protoc_insertion_point(end)\n", string(b))
+}
+
+func TestRunCommand_Warnings(t *testing.T) {
+	descriptorSet := writeDescriptorSet(t, testFileDep)
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		diags.Warnf(nil, "something is deprecated")
+		return nil, nil
+	}
+
+	stderr := bytes.Buffer{}
+	err := protocplugin.RunCommand([]string{"-descriptor_set_in", descriptorSet, "-out", t.TempDir(), "dep.proto"}, &stderr, handle)
+	require.NoError(t, err)
+
+	assert.Equal(t, "warning: something is deprecated\n", stderr.String())
+}
+
+func TestRunCommand_InsertIntoExistingFile(t *testing.T) {
+	descriptorSet := writeDescriptorSet(t, testFileDep)
+	outDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outDir, "dep.pb.go"), []byte("package dep\n\n// @@protoc_insertion_point(imports)\n"), 0o644))
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		return []*protocplugin.GeneratedFile{{Name: "dep.pb.go", Content: "import \"fmt\"\n", InsertionPoint: "imports"}}, nil
+	}
+
+	err := protocplugin.RunCommand([]string{"-descriptor_set_in=" + descriptorSet, "-out=" + outDir, "dep.proto"}, &bytes.Buffer{}, handle)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(filepath.Join(outDir, "dep.pb.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package dep\n\nimport \"fmt\"\n// @@protoc_insertion_point(imports)\n", string(b))
+}
+
+func TestRunCommand_Error(t *testing.T) {
+	descriptorSet := writeDescriptorSet(t, testFileMain, testFileDep)
+	descriptorSetWithoutDep := writeDescriptorSet(t, testFileMain)
+	tests := []struct {
+		name    string
+		args    []string
+		handle  protocplugin.PluginHandler
+		wantErr string
+	}{
+		{
+			name:    "no descriptor set",
+			args:    []string{"main.proto"},
+			wantErr: "-descriptor_set_in is required",
+		},
+		{
+			name:    "no files",
+			args:    []string{"-descriptor_set_in", descriptorSet},
+			wantErr: "no files to generate",
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"-unknown", "main.proto"},
+			wantErr: "flag provided but not defined: -unknown",
+		},
+		{
+			name:    "unknown file",
+			args:    []string{"-descriptor_set_in", descriptorSet, "unknown.proto"},
+			wantErr: `file to generate "unknown.proto" is not in the descriptor set`,
+		},
+		{
+			name:    "missing import",
+			args:    []string{"-descriptor_set_in", descriptorSetWithoutDep, "main.proto"},
+			wantErr: `file "main.proto" imports "dep.proto" which is not in the descriptor set`,
+		},
+		{
+			name: "handler error",
+			args: []string{"-descriptor_set_in", descriptorSet, "main.proto"},
+			handle: func(*pluginpb.CodeGeneratorRequest, map[string]*protocplugin.File, *protocplugin.Registry, *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			wantErr: "something went wrong",
+		},
+		{
+			name: "file outside",
+			args: []string{"-descriptor_set_in", descriptorSet, "main.proto"},
+			handle: func(*pluginpb.CodeGeneratorRequest, map[string]*protocplugin.File, *protocplugin.Registry, *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				return []*protocplugin.GeneratedFile{{Name: "../a.txt"}}, nil
+			},
+			wantErr: `generated file name "../a.txt" must be a relative path within the output directory`,
+		},
+		{
+			name: "insertion point not found",
+			args: []string{"-descriptor_set_in", descriptorSet, "main.proto"},
+			handle: func(*pluginpb.CodeGeneratorRequest, map[string]*protocplugin.File, *protocplugin.Registry, *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				return []*protocplugin.GeneratedFile{{Name: "a.txt"}, {Name: "a.txt", InsertionPoint: "end"}}, nil
+			},
+			wantErr: `a.txt: insertion point "end" not found`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle := tt.handle
+			if handle == nil {
+				handle = listFiles
+			}
+			args := append([]string{"-out", t.TempDir()}, tt.args...)
+
+			err := protocplugin.RunCommand(args, &bytes.Buffer{}, handle)
+
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package protocplugin
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ContextPluginHandler is a function type that handles the code generation request with a context and returns generated files.
+// The context is done when the code generation is cancelled, and the handler should stop generating files then.
+type ContextPluginHandler func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error)
+
+type progressKey struct{}
+
+// progress records the file being generated by a handler.
+type progress struct {
+	mu   sync.Mutex
+	file string
+}
+
+// Generating records that the handler is generating the file with the given name.
+// The name is reported in the error of the response when the code generation is cancelled or the handler panics.
+// It does nothing if the context is not passed from RunContext.
+func Generating(ctx context.Context, name string) {
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.file = name
+	}
+}
+
+// describe returns a phrase describing the file being generated, or an empty string if it is not recorded.
+func (p *progress) describe() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.file == "" {
+		return ""
+	}
+	return " while generating " + p.file
+}
+
+// withCancellation returns a context which is cancelled when the timeout expires, if it is positive, or the process receives SIGINT or SIGTERM.
+func withCancellation(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	cancels := []context.CancelFunc{func() { cancelCause(context.Canceled) }}
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("timeout of %v exceeded", timeout))
+		cancels = append(cancels, cancelTimeout)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			cancelCause(fmt.Errorf("received signal %v", sig))
+		case <-stopped:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(stopped)
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+// callHandler calls the handler and waits for it to return or the context to be done.
+func callHandler(ctx context.Context, fullStack bool, handle func(ctx context.Context) ([]*GeneratedFile, error)) ([]*GeneratedFile, error) {
+	p := &progress{}
+	ctx = context.WithValue(ctx, progressKey{}, p)
+
+	type result struct {
+		files []*GeneratedFile
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		returned := false
+		// Send the result in a deferred call so that it is sent even if the handler calls runtime.Goexit, e.g. by t.Fatal.
+		defer func() {
+			if !returned {
+				r.err = fmt.Errorf("plugin handler exited without returning%s", p.describe())
+			}
+			done <- r
+		}()
+		if panicErr := callSafely(fullStack, func() {
+			r.files, r.err = handle(ctx)
+		}); panicErr != nil {
+			r.err = fmt.Errorf("panic in the plugin handler%s: %w", p.describe(), panicErr)
+		}
+		returned = true
+	}()
+
+	select {
+	case r := <-done:
+		return r.files, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("code generation cancelled%s: %w", p.describe(), context.Cause(ctx))
+	}
+}
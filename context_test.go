@@ -0,0 +1,97 @@
+package protocplugin_test
+
+import (
+	"bytes"
+	"context"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+	"runtime"
+	"testing"
+)
+
+// runRequestContext runs the context-aware handler with the request through RunContext.
+func runRequestContext(t *testing.T, ctx context.Context, req *pluginpb.CodeGeneratorRequest, handle protocplugin.ContextPluginHandler) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	in, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	require.NoError(t, protocplugin.RunContext(ctx, bytes.NewReader(in), &out, handle))
+
+	resp := &pluginpb.CodeGeneratorResponse{}
+	require.NoError(t, proto.Unmarshal(out.Bytes(), resp))
+	return resp
+}
+
+func TestRunContext(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		parameter string
+		handle    protocplugin.ContextPluginHandler
+		wantError string
+		wantFiles int
+	}{
+		{
+			name: "completed",
+			ctx:  context.Background(),
+			handle: func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				protocplugin.Generating(ctx, "dep.txt")
+				return []*protocplugin.GeneratedFile{{Name: "dep.txt"}}, ctx.Err()
+			},
+			parameter: "timeout=1m",
+			wantFiles: 1,
+		},
+		{
+			name:      "timeout",
+			ctx:       context.Background(),
+			parameter: "timeout=10ms",
+			handle: func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				protocplugin.Generating(ctx, "dep.txt")
+				<-ctx.Done()
+				return nil, nil
+			},
+			wantError: "code generation cancelled while generating dep.txt: timeout of 10ms exceeded",
+		},
+		{
+			name: "cancelled",
+			ctx:  cancelled,
+			handle: func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				select {}
+			},
+			wantError: "code generation cancelled: context canceled",
+		},
+		{
+			name: "goexit",
+			ctx:  context.Background(),
+			handle: func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				protocplugin.Generating(ctx, "dep.txt")
+				runtime.Goexit()
+				return nil, nil
+			},
+			wantError: "plugin handler exited without returning while generating dep.txt",
+		},
+		{
+			name:      "invalid timeout",
+			ctx:       context.Background(),
+			parameter: "timeout=10",
+			handle: func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				return nil, nil
+			},
+			wantError: `invalid parameter: invalid value "10" for parameter "timeout": want a duration such as 30s`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := runRequestContext(t, tt.ctx, newRequest(t, tt.parameter, testFileDep), tt.handle)
+			assert.Equal(t, tt.wantError, resp.GetError())
+			assert.Len(t, resp.File, tt.wantFiles)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package protocplugin_test
+
+import (
+	"context"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRunContext_Signal(t *testing.T) {
+	handle := func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		protocplugin.Generating(ctx, "dep.txt")
+		if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+			return nil, err
+		}
+		<-ctx.Done()
+		return nil, nil
+	}
+	resp := runRequestContext(t, context.Background(), newRequest(t, "", testFileDep), handle)
+	assert.Equal(t, "code generation cancelled while generating dep.txt: received signal interrupt", resp.GetError())
+	assert.Empty(t, resp.File)
+}
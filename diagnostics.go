@@ -0,0 +1,98 @@
+package protocplugin
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Severity represents the severity of a diagnostic.
+type Severity int
+
+const (
+	SeverityError   Severity = iota // SeverityError fails the code generation.
+	SeverityWarning                 // SeverityWarning is reported without failing the code generation.
+)
+
+// Diagnostic represents a problem reported by a plugin handler.
+type Diagnostic struct {
+	Severity Severity // Severity is the severity of the diagnostic.
+	Element  Element  // Element is the element the diagnostic is attached to, or nil if it is not attached to any element.
+	Message  string   // Message is the message of the diagnostic.
+}
+
+// String returns the diagnostic in the style of protoc, that is "file:line:column: message".
+func (d *Diagnostic) String() string {
+	msg := d.Message
+	if d.Severity == SeverityWarning {
+		msg = "warning: " + msg
+	}
+	if d.Element == nil {
+		return msg
+	}
+	return LocationOf(d.Element).String() + ": " + msg
+}
+
+// Diagnostics collects diagnostics reported by a plugin handler.
+// It is safe for concurrent use.
+type Diagnostics struct {
+	mu          sync.Mutex
+	diagnostics []*Diagnostic
+}
+
+func (d *Diagnostics) add(severity Severity, e Element, format string, args ...any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.diagnostics = append(d.diagnostics, &Diagnostic{Severity: severity, Element: e, Message: fmt.Sprintf(format, args...)})
+}
+
+// Errorf reports an error attached to the given element, which may be nil.
+func (d *Diagnostics) Errorf(e Element, format string, args ...any) {
+	d.add(SeverityError, e, format, args...)
+}
+
+// Warnf reports a warning attached to the given element, which may be nil.
+func (d *Diagnostics) Warnf(e Element, format string, args ...any) {
+	d.add(SeverityWarning, e, format, args...)
+}
+
+// All returns all the reported diagnostics in the reported order.
+func (d *Diagnostics) All() []*Diagnostic {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Diagnostic{}, d.diagnostics...)
+}
+
+// Errors returns the reported errors in the reported order.
+func (d *Diagnostics) Errors() []*Diagnostic {
+	return d.filter(SeverityError)
+}
+
+// Warnings returns the reported warnings in the reported order.
+func (d *Diagnostics) Warnings() []*Diagnostic {
+	return d.filter(SeverityWarning)
+}
+
+// HasErrors reports whether any error has been reported.
+func (d *Diagnostics) HasErrors() bool {
+	return len(d.Errors()) > 0
+}
+
+func (d *Diagnostics) filter(severity Severity) []*Diagnostic {
+	filtered := []*Diagnostic{}
+	for _, diag := range d.All() {
+		if diag.Severity == severity {
+			filtered = append(filtered, diag)
+		}
+	}
+	return filtered
+}
+
+// formatDiagnostics renders the given diagnostics one per line.
+func formatDiagnostics(diagnostics []*Diagnostic) string {
+	lines := []string{}
+	for _, diag := range diagnostics {
+		lines = append(lines, diag.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,92 @@
+package protocplugin_test
+
+import (
+	"fmt"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+func TestRun_Diagnostics(t *testing.T) {
+	tests := []struct {
+		name      string
+		handle    protocplugin.PluginHandler
+		wantError string
+		wantFiles int
+	}{
+		{
+			name: "errors with positions",
+			handle: func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				message := files["oneof.proto"].Messages[0]
+				diags.Errorf(message.Oneofs[0], "oneof %s is not allowed", message.Oneofs[0].Desc.Name())
+				diags.Warnf(message, "message is deprecated")
+				diags.Errorf(message.Fields[0], "field %s is not allowed", message.Fields[0].Desc.Name())
+				diags.Errorf(nil, "something is wrong")
+				return []*protocplugin.GeneratedFile{{Name: "out.txt"}}, nil
+			},
+			wantError: "oneof.proto:6:3: oneof choice is not allowed\n" +
+				"oneof.proto: field a is not allowed\n" +
+				"something is wrong",
+		},
+		{
+			name: "errors and handler error",
+			handle: func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				diags.Errorf(files["oneof.proto"].Messages[0].Oneofs[0], "invalid oneof")
+				return nil, fmt.Errorf("failed")
+			},
+			wantError: "oneof.proto:6:3: invalid oneof\nfailed",
+		},
+		{
+			name: "warnings only",
+			handle: func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				diags.Warnf(files["oneof.proto"].Messages[0].Oneofs[0], "oneof is deprecated")
+				return []*protocplugin.GeneratedFile{{Name: "out.txt"}}, nil
+			},
+			wantFiles: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := runPlugin(t, tt.handle, testFileOneof)
+			assert.Equal(t, tt.wantError, resp.GetError())
+			assert.Len(t, resp.File, tt.wantFiles)
+		})
+	}
+}
+
+func TestDiagnostic_String(t *testing.T) {
+	file := &protocplugin.File{Location: protocplugin.Location{File: "foo.proto"}}
+	message := &protocplugin.Message{Location: protocplugin.Location{File: "foo.proto", Line: 12, Column: 3}}
+	tests := []struct {
+		name string
+		sut  protocplugin.Diagnostic
+		want string
+	}{
+		{
+			name: "error",
+			sut:  protocplugin.Diagnostic{Severity: protocplugin.SeverityError, Element: message, Message: "bad message"},
+			want: "foo.proto:12:3: bad message",
+		},
+		{
+			name: "warning",
+			sut:  protocplugin.Diagnostic{Severity: protocplugin.SeverityWarning, Element: message, Message: "bad message"},
+			want: "foo.proto:12:3: warning: bad message",
+		},
+		{
+			name: "unknown position",
+			sut:  protocplugin.Diagnostic{Severity: protocplugin.SeverityError, Element: file, Message: "bad file"},
+			want: "foo.proto: bad file",
+		},
+		{
+			name: "no element",
+			sut:  protocplugin.Diagnostic{Severity: protocplugin.SeverityError, Message: "bad request"},
+			want: "bad request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.sut.String())
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package protocplugin
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Features represents the resolved values of the protobuf editions features of an element.
+// The values are inherited from the parent elements and the defaults of the edition of the file,
+// and the equivalent features are inferred for proto2 and proto3 files.
+type Features struct {
+	*descriptorpb.FeatureSet // FeatureSet is the embedded feature set, in which all the features defined in descriptor.proto are set.
+}
+
+// editionDefaults returns the default features of the given edition.
+func editionDefaults(edition descriptorpb.Edition) *descriptorpb.FeatureSet {
+	switch {
+	case edition <= descriptorpb.Edition_EDITION_PROTO2:
+		return &descriptorpb.FeatureSet{
+			FieldPresence:         descriptorpb.FeatureSet_EXPLICIT.Enum(),
+			EnumType:              descriptorpb.FeatureSet_CLOSED.Enum(),
+			RepeatedFieldEncoding: descriptorpb.FeatureSet_EXPANDED.Enum(),
+			Utf8Validation:        descriptorpb.FeatureSet_NONE.Enum(),
+			MessageEncoding:       descriptorpb.FeatureSet_LENGTH_PREFIXED.Enum(),
+			JsonFormat:            descriptorpb.FeatureSet_LEGACY_BEST_EFFORT.Enum(),
+		}
+	case edition == descriptorpb.Edition_EDITION_PROTO3:
+		return &descriptorpb.FeatureSet{
+			FieldPresence:         descriptorpb.FeatureSet_IMPLICIT.Enum(),
+			EnumType:              descriptorpb.FeatureSet_OPEN.Enum(),
+			RepeatedFieldEncoding: descriptorpb.FeatureSet_PACKED.Enum(),
+			Utf8Validation:        descriptorpb.FeatureSet_VERIFY.Enum(),
+			MessageEncoding:       descriptorpb.FeatureSet_LENGTH_PREFIXED.Enum(),
+			JsonFormat:            descriptorpb.FeatureSet_ALLOW.Enum(),
+		}
+	default:
+		return &descriptorpb.FeatureSet{
+			FieldPresence:         descriptorpb.FeatureSet_EXPLICIT.Enum(),
+			EnumType:              descriptorpb.FeatureSet_OPEN.Enum(),
+			RepeatedFieldEncoding: descriptorpb.FeatureSet_PACKED.Enum(),
+			Utf8Validation:        descriptorpb.FeatureSet_VERIFY.Enum(),
+			MessageEncoding:       descriptorpb.FeatureSet_LENGTH_PREFIXED.Enum(),
+			JsonFormat:            descriptorpb.FeatureSet_ALLOW.Enum(),
+		}
+	}
+}
+
+// fileEdition returns the edition of the file, where proto2 and proto3 are regarded as editions.
+func fileEdition(fd *descriptorpb.FileDescriptorProto) descriptorpb.Edition {
+	switch fd.GetSyntax() {
+	case "editions":
+		return fd.GetEdition()
+	case "proto3":
+		return descriptorpb.Edition_EDITION_PROTO3
+	default:
+		return descriptorpb.Edition_EDITION_PROTO2
+	}
+}
+
+// inheritFeatures returns the features overriding the parent features with the features set explicitly.
+func inheritFeatures(parent *Features, features *descriptorpb.FeatureSet) *Features {
+	resolved := proto.Clone(parent.FeatureSet).(*descriptorpb.FeatureSet)
+	if features != nil {
+		proto.Merge(resolved, features)
+	}
+	return &Features{FeatureSet: resolved}
+}
+
+// featuresOf returns the features of the file or message.
+func featuresOf(e Element) *Features {
+	switch e := e.(type) {
+	case *File:
+		return e.Features
+	case *Message:
+		return e.Features
+	default:
+		panic("unexpected parent element")
+	}
+}
+
+// fieldFeatures resolves the features of the field, inferring the features equivalent to proto2 and proto3 semantics.
+func fieldFeatures(parent *Features, f protoreflect.FieldDescriptor) *Features {
+	if o := f.ContainingOneof(); o != nil {
+		parent = inheritFeatures(parent, o.Options().(*descriptorpb.OneofOptions).GetFeatures())
+	}
+	options := f.Options().(*descriptorpb.FieldOptions)
+	features := inheritFeatures(parent, options.GetFeatures())
+	switch f.ParentFile().Syntax() {
+	case protoreflect.Proto2:
+		if f.Cardinality() == protoreflect.Required {
+			features.FieldPresence = descriptorpb.FeatureSet_LEGACY_REQUIRED.Enum()
+		}
+		if options.GetPacked() {
+			features.RepeatedFieldEncoding = descriptorpb.FeatureSet_PACKED.Enum()
+		}
+		if f.Kind() == protoreflect.GroupKind {
+			features.MessageEncoding = descriptorpb.FeatureSet_DELIMITED.Enum()
+		}
+	case protoreflect.Proto3:
+		if f.HasOptionalKeyword() {
+			features.FieldPresence = descriptorpb.FeatureSet_EXPLICIT.Enum()
+		}
+		if options != nil && options.Packed != nil && !options.GetPacked() {
+			features.RepeatedFieldEncoding = descriptorpb.FeatureSet_EXPANDED.Enum()
+		}
+	}
+	return features
+}
+
+// HasPresence reports whether the field tracks presence, that is whether an unset field is distinguished from a field set to the default value.
+// Message fields, fields in oneofs and extension fields always track presence, and repeated fields never do.
+// Otherwise, it depends on the field_presence feature.
+func (f *Field) HasPresence() bool {
+	switch {
+	case f.Desc.Cardinality() == protoreflect.Repeated:
+		return false
+	case f.Desc.IsExtension(), f.Desc.Message() != nil, f.Desc.ContainingOneof() != nil:
+		return true
+	}
+	return f.Features.GetFieldPresence() != descriptorpb.FeatureSet_IMPLICIT
+}
+
+// IsRequired reports whether the field is a legacy required field.
+func (f *Field) IsRequired() bool {
+	return f.Features.GetFieldPresence() == descriptorpb.FeatureSet_LEGACY_REQUIRED
+}
+
+// IsPacked reports whether the field is a repeated field of a scalar numeric type encoded in the packed encoding.
+func (f *Field) IsPacked() bool {
+	if f.Desc.Cardinality() != protoreflect.Repeated || f.IsMap {
+		return false
+	}
+	switch f.Desc.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	}
+	return f.Features.GetRepeatedFieldEncoding() == descriptorpb.FeatureSet_PACKED
+}
+
+// IsDelimited reports whether the field is a message field encoded in the delimited encoding, that is as a group.
+func (f *Field) IsDelimited() bool {
+	if f.Desc.Message() == nil || f.IsMap {
+		return false
+	}
+	return f.Features.GetMessageEncoding() == descriptorpb.FeatureSet_DELIMITED
+}
+
+// ValidatesUTF8 reports whether the field is a string field whose values are validated as UTF-8 when parsed.
+func (f *Field) ValidatesUTF8() bool {
+	return f.Desc.Kind() == protoreflect.StringKind && f.Features.GetUtf8Validation() == descriptorpb.FeatureSet_VERIFY
+}
+
+// IsClosed reports whether the enum is closed, that is whether unknown values are rejected when parsed.
+func (e *Enum) IsClosed() bool {
+	return e.Features.GetEnumType() == descriptorpb.FeatureSet_CLOSED
+}
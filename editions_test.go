@@ -0,0 +1,132 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+const testFileFeaturesEditions = `
+name: "features_editions.proto"
+package: "features.editions"
+syntax: "editions"
+edition: EDITION_2023
+options: { go_package: "example.com/features/editions" features: { field_presence: IMPLICIT } }
+message_type: {
+  name: "Message"
+  field: { name: "implicit" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "implicit" }
+  field: { name: "explicit" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "explicit" options: { features: { field_presence: EXPLICIT } } }
+  field: { name: "packed" number: 3 label: LABEL_REPEATED type: TYPE_INT32 json_name: "packed" }
+  field: { name: "expanded" number: 4 label: LABEL_REPEATED type: TYPE_INT32 json_name: "expanded" options: { features: { repeated_field_encoding: EXPANDED } } }
+  field: { name: "delimited" number: 5 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".features.editions.Message" json_name: "delimited" options: { features: { message_encoding: DELIMITED } } }
+  field: { name: "closed" number: 6 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".features.editions.Closed" json_name: "closed" options: { features: { field_presence: EXPLICIT } } }
+  options: { features: { utf8_validation: NONE } }
+}
+enum_type: {
+  name: "Closed"
+  value: { name: "CLOSED_ONE" number: 1 }
+  options: { features: { enum_type: CLOSED } }
+}
+`
+
+const testFileFeaturesProto2 = `
+name: "features_proto2.proto"
+package: "features.proto2"
+options: { go_package: "example.com/features/proto2" }
+message_type: {
+  name: "Message"
+  field: { name: "optional" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "optional" }
+  field: { name: "required" number: 2 label: LABEL_REQUIRED type: TYPE_INT32 json_name: "required" }
+  field: { name: "expanded" number: 3 label: LABEL_REPEATED type: TYPE_INT32 json_name: "expanded" }
+  field: { name: "packed" number: 4 label: LABEL_REPEATED type: TYPE_INT32 json_name: "packed" options: { packed: true } }
+  field: { name: "group" number: 5 label: LABEL_OPTIONAL type: TYPE_GROUP type_name: ".features.proto2.Message.Group" json_name: "group" }
+  nested_type: { name: "Group" }
+}
+enum_type: { name: "Enum" value: { name: "ENUM_ONE" number: 1 } }
+`
+
+const testFileFeaturesProto3 = `
+name: "features_proto3.proto"
+package: "features.proto3"
+syntax: "proto3"
+options: { go_package: "example.com/features/proto3" }
+message_type: {
+  name: "Message"
+  field: { name: "implicit" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "implicit" }
+  field: { name: "explicit" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "explicit" oneof_index: 1 proto3_optional: true }
+  field: { name: "packed" number: 3 label: LABEL_REPEATED type: TYPE_INT32 json_name: "packed" }
+  field: { name: "expanded" number: 4 label: LABEL_REPEATED type: TYPE_INT32 json_name: "expanded" options: { packed: false } }
+  field: { name: "in_oneof" number: 5 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "inOneof" oneof_index: 0 }
+  oneof_decl: { name: "choice" }
+  oneof_decl: { name: "_explicit" }
+}
+enum_type: { name: "Enum" value: { name: "ENUM_UNSPECIFIED" number: 0 } }
+`
+
+func TestRun_Features(t *testing.T) {
+	type fieldWant struct {
+		presence, required, packed, delimited, utf8 bool
+	}
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		tests := []struct {
+			name  string
+			field *protocplugin.Field
+			want  fieldWant
+		}{
+			{name: "editions implicit", field: files["features_editions.proto"].Messages[0].Fields[0], want: fieldWant{}},
+			{name: "editions explicit", field: files["features_editions.proto"].Messages[0].Fields[1], want: fieldWant{presence: true}},
+			{name: "editions packed", field: files["features_editions.proto"].Messages[0].Fields[2], want: fieldWant{packed: true}},
+			{name: "editions expanded", field: files["features_editions.proto"].Messages[0].Fields[3], want: fieldWant{}},
+			{name: "editions delimited", field: files["features_editions.proto"].Messages[0].Fields[4], want: fieldWant{presence: true, delimited: true}},
+			{name: "proto2 optional", field: files["features_proto2.proto"].Messages[0].Fields[0], want: fieldWant{presence: true}},
+			{name: "proto2 required", field: files["features_proto2.proto"].Messages[0].Fields[1], want: fieldWant{presence: true, required: true}},
+			{name: "proto2 expanded", field: files["features_proto2.proto"].Messages[0].Fields[2], want: fieldWant{}},
+			{name: "proto2 packed", field: files["features_proto2.proto"].Messages[0].Fields[3], want: fieldWant{packed: true}},
+			{name: "proto2 group", field: files["features_proto2.proto"].Messages[0].Fields[4], want: fieldWant{presence: true, delimited: true}},
+			{name: "proto3 implicit", field: files["features_proto3.proto"].Messages[0].Fields[0], want: fieldWant{utf8: true}},
+			{name: "proto3 optional", field: files["features_proto3.proto"].Messages[0].Fields[1], want: fieldWant{presence: true}},
+			{name: "proto3 packed", field: files["features_proto3.proto"].Messages[0].Fields[2], want: fieldWant{packed: true}},
+			{name: "proto3 expanded", field: files["features_proto3.proto"].Messages[0].Fields[3], want: fieldWant{}},
+			{name: "proto3 oneof", field: files["features_proto3.proto"].Messages[0].Fields[4], want: fieldWant{presence: true}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got := fieldWant{
+					presence:  tt.field.HasPresence(),
+					required:  tt.field.IsRequired(),
+					packed:    tt.field.IsPacked(),
+					delimited: tt.field.IsDelimited(),
+					utf8:      tt.field.ValidatesUTF8(),
+				}
+				assert.Equal(t, tt.want, got)
+			})
+		}
+
+		t.Run("inheritance", func(t *testing.T) {
+			file := files["features_editions.proto"]
+			message := file.Messages[0]
+			assert.Equal(t, descriptorpb.FeatureSet_IMPLICIT, file.Features.GetFieldPresence())
+			assert.Equal(t, descriptorpb.FeatureSet_VERIFY, file.Features.GetUtf8Validation())
+			assert.Equal(t, descriptorpb.FeatureSet_IMPLICIT, message.Features.GetFieldPresence())
+			assert.Equal(t, descriptorpb.FeatureSet_NONE, message.Features.GetUtf8Validation())
+			assert.Equal(t, descriptorpb.FeatureSet_NONE, message.Fields[0].Features.GetUtf8Validation())
+			assert.Equal(t, descriptorpb.FeatureSet_ALLOW, message.Fields[0].Features.GetJsonFormat())
+			assert.Equal(t, descriptorpb.FeatureSet_EXPLICIT, message.Fields[5].Features.GetFieldPresence())
+			assert.Equal(t, descriptorpb.FeatureSet_IMPLICIT, files["features_proto3.proto"].Messages[0].Oneofs[0].Features.GetFieldPresence())
+		})
+
+		t.Run("enums", func(t *testing.T) {
+			assert.True(t, files["features_editions.proto"].Enums[0].IsClosed())
+			assert.True(t, files["features_proto2.proto"].Enums[0].IsClosed())
+			assert.False(t, files["features_proto3.proto"].Enums[0].IsClosed())
+		})
+		return nil, nil
+	}
+	req := newRequest(t, "", testFileFeaturesEditions, testFileFeaturesProto2, testFileFeaturesProto3)
+	resp := runRequest(t, req, handle,
+		protocplugin.WithSupportedFeatures(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL),
+		protocplugin.WithSupportedEditions(descriptorpb.Edition_EDITION_PROTO2, descriptorpb.Edition_EDITION_2023))
+	assert.Empty(t, resp.GetError())
+}
@@ -0,0 +1,88 @@
+package protocplugin
+
+// Element represents an element of the protobuf schema model.
+// It is implemented by *File, *Message, *Field, *Oneof, *Enum, *EnumValue, *Service and *Method.
+type Element interface {
+	parent() Element
+	location() Location
+}
+
+var (
+	_ Element = (*File)(nil)
+	_ Element = (*Message)(nil)
+	_ Element = (*Field)(nil)
+	_ Element = (*Oneof)(nil)
+	_ Element = (*Enum)(nil)
+	_ Element = (*EnumValue)(nil)
+	_ Element = (*Service)(nil)
+	_ Element = (*Method)(nil)
+)
+
+func (f *File) parent() Element { return nil }
+
+func (m *Message) parent() Element { return m.Parent }
+
+func (f *Field) parent() Element {
+	if f.Parent == nil {
+		return f.file // extension fields declared at the top level of a file
+	}
+	return f.Parent
+}
+
+func (o *Oneof) parent() Element { return o.Parent }
+
+func (e *Enum) parent() Element { return e.Parent }
+
+func (v *EnumValue) parent() Element { return v.Parent }
+
+func (s *Service) parent() Element { return s.Parent }
+
+func (m *Method) parent() Element { return m.Parent }
+
+func (f *File) location() Location { return f.Location }
+
+func (m *Message) location() Location { return m.Location }
+
+func (f *Field) location() Location { return f.Location }
+
+func (o *Oneof) location() Location { return o.Location }
+
+func (e *Enum) location() Location { return e.Location }
+
+func (v *EnumValue) location() Location { return v.Location }
+
+func (s *Service) location() Location { return s.Location }
+
+func (m *Method) location() Location { return m.Location }
+
+// Parent returns the element directly containing the given element, or nil if the element is a file.
+// The parent of a message or an enum is either a *File or a *Message.
+// The parent of a field is a *Message, or a *File if the field is an extension declared at the top level of a file.
+func Parent(e Element) Element {
+	return e.parent()
+}
+
+// FileOf returns the file containing the given element, or the element itself if it is a file.
+func FileOf(e Element) *File {
+	for e != nil {
+		if f, ok := e.(*File); ok {
+			return f
+		}
+		e = e.parent()
+	}
+	return nil
+}
+
+// Ancestors returns the elements containing the given element, ordered from the direct parent to the file.
+func Ancestors(e Element) []Element {
+	ancestors := []Element{}
+	for p := e.parent(); p != nil; p = p.parent() {
+		ancestors = append(ancestors, p)
+	}
+	return ancestors
+}
+
+// LocationOf returns the location of the given element in its source file.
+func LocationOf(e Element) Location {
+	return e.location()
+}
@@ -0,0 +1,106 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+func TestParent(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		main, ext := files["main.proto"], files["ext.proto"]
+		request := main.Messages[0]
+		inner := request.Messages[0]
+		service := main.Services[0]
+
+		tests := []struct {
+			name          string
+			sut           protocplugin.Element
+			wantParent    protocplugin.Element
+			wantFile      *protocplugin.File
+			wantAncestors []protocplugin.Element
+		}{
+			{
+				name:          "file",
+				sut:           main,
+				wantParent:    nil,
+				wantFile:      main,
+				wantAncestors: []protocplugin.Element{},
+			},
+			{
+				name:          "top level message",
+				sut:           request,
+				wantParent:    main,
+				wantFile:      main,
+				wantAncestors: []protocplugin.Element{main},
+			},
+			{
+				name:          "nested message",
+				sut:           inner,
+				wantParent:    request,
+				wantFile:      main,
+				wantAncestors: []protocplugin.Element{request, main},
+			},
+			{
+				name:          "field",
+				sut:           request.Fields[0],
+				wantParent:    request,
+				wantFile:      main,
+				wantAncestors: []protocplugin.Element{request, main},
+			},
+			{
+				name:          "oneof",
+				sut:           request.Oneofs[0],
+				wantParent:    request,
+				wantFile:      main,
+				wantAncestors: []protocplugin.Element{request, main},
+			},
+			{
+				name:          "top level enum",
+				sut:           files["dep.proto"].Enums[0],
+				wantParent:    files["dep.proto"],
+				wantFile:      files["dep.proto"],
+				wantAncestors: []protocplugin.Element{files["dep.proto"]},
+			},
+			{
+				name:          "enum value",
+				sut:           files["dep.proto"].Enums[0].Values[0],
+				wantParent:    files["dep.proto"].Enums[0],
+				wantFile:      files["dep.proto"],
+				wantAncestors: []protocplugin.Element{files["dep.proto"].Enums[0], files["dep.proto"]},
+			},
+			{
+				name:          "method",
+				sut:           service.Methods[0],
+				wantParent:    service,
+				wantFile:      main,
+				wantAncestors: []protocplugin.Element{service, main},
+			},
+			{
+				name:          "top level extension",
+				sut:           ext.Extensions[0],
+				wantParent:    ext,
+				wantFile:      ext,
+				wantAncestors: []protocplugin.Element{ext},
+			},
+			{
+				name:          "nested extension",
+				sut:           ext.Messages[0].Extensions[0],
+				wantParent:    ext.Messages[0],
+				wantFile:      ext,
+				wantAncestors: []protocplugin.Element{ext.Messages[0], ext},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.wantParent, protocplugin.Parent(tt.sut))
+				assert.Same(t, tt.wantFile, protocplugin.FileOf(tt.sut))
+				assert.Equal(t, tt.wantAncestors, protocplugin.Ancestors(tt.sut))
+			})
+		}
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileDep, testFileMain, testFileExt)
+	assert.Empty(t, resp.GetError())
+}
@@ -0,0 +1,95 @@
+package protocplugin
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"strings"
+)
+
+// WithSupportedFeatures declares the protobuf language features supported by the plugin.
+// They are advertised to protoc in the response, and files to generate using unsupported features are rejected before the handler is called.
+// FEATURE_SUPPORTS_EDITIONS requires the range of supported editions declared by WithSupportedEditions.
+func WithSupportedFeatures(features ...pluginpb.CodeGeneratorResponse_Feature) Option {
+	return func(c *config) {
+		for _, f := range features {
+			c.supportedFeatures |= uint64(f)
+		}
+	}
+}
+
+// WithSupportedEditions declares the range of editions supported by the plugin, which implies FEATURE_SUPPORTS_EDITIONS.
+// The range is advertised to protoc in the response, and files to generate with editions out of the range are rejected before the handler is called.
+func WithSupportedEditions(minimum, maximum descriptorpb.Edition) Option {
+	return func(c *config) {
+		c.supportedFeatures |= uint64(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+		c.minimumEdition, c.maximumEdition = minimum, maximum
+	}
+}
+
+func (c *config) supports(feature pluginpb.CodeGeneratorResponse_Feature) bool {
+	return c.supportedFeatures&uint64(feature) != 0
+}
+
+// advertiseSupport sets the supported features and editions to the response.
+func (c *config) advertiseSupport(resp *pluginpb.CodeGeneratorResponse) {
+	if c.supportedFeatures != 0 {
+		resp.SupportedFeatures = &c.supportedFeatures
+	}
+	if c.supports(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS) && c.minimumEdition != descriptorpb.Edition_EDITION_UNKNOWN {
+		resp.MinimumEdition = (*int32)(&c.minimumEdition)
+		resp.MaximumEdition = (*int32)(&c.maximumEdition)
+	}
+}
+
+// checkSupport returns an error describing all the files to generate which use features or editions not supported by the plugin.
+func (c *config) checkSupport(req *pluginpb.CodeGeneratorRequest) error {
+	if c.supports(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS) && c.minimumEdition == descriptorpb.Edition_EDITION_UNKNOWN {
+		return fmt.Errorf("FEATURE_SUPPORTS_EDITIONS is declared without the range of supported editions: the plugin must declare it by WithSupportedEditions")
+	}
+	toGenerate := map[string]bool{}
+	for _, name := range req.GetFileToGenerate() {
+		toGenerate[name] = true
+	}
+	errs := []error{}
+	for _, fd := range req.GetProtoFile() {
+		if !toGenerate[fd.GetName()] {
+			continue
+		}
+		switch fd.GetSyntax() {
+		case "editions":
+			edition := fd.GetEdition()
+			switch {
+			case !c.supports(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS):
+				errs = append(errs, fmt.Errorf("%s: editions are not supported by this plugin", fd.GetName()))
+			case edition < c.minimumEdition || edition > c.maximumEdition:
+				errs = append(errs, fmt.Errorf("%s: edition %s is not supported by this plugin, which supports editions from %s to %s",
+					fd.GetName(), editionName(edition), editionName(c.minimumEdition), editionName(c.maximumEdition)))
+			}
+		case "proto3":
+			if !c.supports(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL) && hasProto3Optional(fd.GetMessageType()) {
+				errs = append(errs, fmt.Errorf("%s: proto3 optional fields are not supported by this plugin", fd.GetName()))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func hasProto3Optional(messages []*descriptorpb.DescriptorProto) bool {
+	for _, m := range messages {
+		for _, f := range m.GetField() {
+			if f.GetProto3Optional() {
+				return true
+			}
+		}
+		if hasProto3Optional(m.GetNestedType()) {
+			return true
+		}
+	}
+	return false
+}
+
+func editionName(e descriptorpb.Edition) string {
+	return strings.TrimPrefix(e.String(), "EDITION_")
+}
@@ -0,0 +1,107 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+const testFileEditions = `
+name: "editions.proto"
+package: "editions"
+syntax: "editions"
+edition: EDITION_2023
+options: { go_package: "example.com/editions" }
+message_type: {
+  name: "Message"
+  field: { name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name" }
+}
+`
+
+func TestRun_SupportedFeatures(t *testing.T) {
+	const (
+		proto3Optional = pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL
+		editions       = pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS
+	)
+	tests := []struct {
+		name         string
+		files        []string
+		generate     []string
+		opts         []protocplugin.Option
+		wantFeatures *uint64
+		wantMinimum  *int32
+		wantMaximum  *int32
+		wantError    string
+	}{
+		{
+			name:      "proto3 optional not supported",
+			files:     []string{testFileOneof},
+			wantError: "oneof.proto: proto3 optional fields are not supported by this plugin",
+		},
+		{
+			name:         "proto3 optional supported",
+			files:        []string{testFileOneof},
+			opts:         []protocplugin.Option{protocplugin.WithSupportedFeatures(proto3Optional)},
+			wantFeatures: proto.Uint64(uint64(proto3Optional)),
+		},
+		{
+			name:      "editions not supported",
+			files:     []string{testFileEditions, testFileOneof},
+			wantError: "editions.proto: editions are not supported by this plugin\noneof.proto: proto3 optional fields are not supported by this plugin",
+		},
+		{
+			name:     "editions not supported in dependencies",
+			files:    []string{testFileEditions, testFileDep},
+			generate: []string{"dep.proto"},
+		},
+		{
+			name:         "editions supported",
+			files:        []string{testFileEditions},
+			opts:         []protocplugin.Option{protocplugin.WithSupportedEditions(descriptorpb.Edition_EDITION_PROTO2, descriptorpb.Edition_EDITION_2023)},
+			wantFeatures: proto.Uint64(uint64(editions)),
+			wantMinimum:  proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO2)),
+			wantMaximum:  proto.Int32(int32(descriptorpb.Edition_EDITION_2023)),
+		},
+		{
+			name:         "editions supported without range",
+			files:        []string{testFileDep},
+			opts:         []protocplugin.Option{protocplugin.WithSupportedFeatures(editions)},
+			wantFeatures: proto.Uint64(uint64(editions)),
+			wantError:    "FEATURE_SUPPORTS_EDITIONS is declared without the range of supported editions: the plugin must declare it by WithSupportedEditions",
+		},
+		{
+			name:  "edition out of range",
+			files: []string{testFileEditions},
+			opts: []protocplugin.Option{
+				protocplugin.WithSupportedFeatures(proto3Optional),
+				protocplugin.WithSupportedEditions(descriptorpb.Edition_EDITION_PROTO2, descriptorpb.Edition_EDITION_PROTO3),
+			},
+			wantFeatures: proto.Uint64(uint64(proto3Optional | editions)),
+			wantMinimum:  proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO2)),
+			wantMaximum:  proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO3)),
+			wantError:    "editions.proto: edition 2023 is not supported by this plugin, which supports editions from PROTO2 to PROTO3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				called = true
+				return nil, nil
+			}
+			req := newRequest(t, "", tt.files...)
+			if tt.generate != nil {
+				req.FileToGenerate = tt.generate
+			}
+			resp := runRequest(t, req, handle, tt.opts...)
+			assert.Equal(t, tt.wantError, resp.GetError())
+			assert.Equal(t, tt.wantError == "", called)
+			assert.Equal(t, tt.wantFeatures, resp.SupportedFeatures)
+			assert.Equal(t, tt.wantMinimum, resp.MinimumEdition)
+			assert.Equal(t, tt.wantMaximum, resp.MaximumEdition)
+		})
+	}
+}
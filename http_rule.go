@@ -1,10 +1,12 @@
 package protocplugin
 
 import (
+	"fmt"
 	"google.golang.org/genproto/googleapis/api/annotations"
 	"net/http"
-	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type HttpRule struct {
@@ -31,14 +33,14 @@ func (r *HttpRule) Method() string {
 	}
 }
 
-// PathTemplate returns a path template object parsed from the HTTP rule.
+// PathTemplate returns a path template object parsed from the HTTP rule, or nil if the rule has no pattern.
 // The path template syntax is described in https://cloud.google.com/endpoints/docs/grpc-service-config/reference/rpc/google.api#path-template-syntax
-// Verb is not supported
-func (r *HttpRule) PathTemplate() *HttpRulePathTemplate {
+// An error is returned if the path is not a valid path template, which is a *PathTemplateError.
+func (r *HttpRule) PathTemplate() (*HttpRulePathTemplate, error) {
 	var pathPattern string
 	switch p := r.GetPattern().(type) {
 	default:
-		return nil
+		return nil, nil
 	case *annotations.HttpRule_Get:
 		pathPattern = p.Get
 	case *annotations.HttpRule_Post:
@@ -52,61 +54,221 @@ func (r *HttpRule) PathTemplate() *HttpRulePathTemplate {
 	case *annotations.HttpRule_Custom:
 		pathPattern = p.Custom.Path
 	}
-	return &HttpRulePathTemplate{
-		Segments: parsePathTemplateSegments(pathPattern),
+	return ParsePathTemplate(pathPattern)
+}
+
+// PathTemplateError represents a syntax error in a path template.
+type PathTemplateError struct {
+	Template string // Template is the path template containing the error.
+	Offset   int    // Offset is the byte offset of the error in Template.
+	Expected string // Expected describes what is expected at Offset.
+}
+
+// Error returns the error message with the offset, what is expected and what is found there.
+func (e *PathTemplateError) Error() string {
+	found := "end of template"
+	if e.Offset < len(e.Template) {
+		r, _ := utf8.DecodeRuneInString(e.Template[e.Offset:])
+		found = strconv.QuoteRune(r)
 	}
+	return fmt.Sprintf("invalid path template %q: expected %s at offset %d, found %s", e.Template, e.Expected, e.Offset, found)
 }
 
-var (
-	regexSingleAsterisk = regexp.MustCompile(`^\*`)
-	regexDoubleAsterisk = regexp.MustCompile(`^\*\*`)
-	regexLiteral        = regexp.MustCompile(`^(\*|\*\*|[^{/]+)`)
-	regexVariable       = regexp.MustCompile(`^\{[^}]+}`)
-)
+// ParsePathTemplate parses the path template following the grammar in google/api/http.proto:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	FieldPath = IDENT { "." IDENT } ;
+//	Verb     = ":" LITERAL ;
+//
+// Variables must not be nested, and "**" must be the last segment.
+// A literal may contain ":", but the text after the last ":" in the last segment is the verb, e.g. "undelete" of "/v1/{name=projects/*}:undelete".
+// An error is returned if the template is invalid, which is a *PathTemplateError.
+func ParsePathTemplate(template string) (*HttpRulePathTemplate, error) {
+	p := &pathTemplateParser{template: template}
+	if err := p.expect('/', `"/"`); err != nil {
+		return nil, err
+	}
+	segments, err := p.parseSegments(false)
+	if err != nil {
+		return nil, err
+	}
+	verb, err := p.parseVerb(segments[len(segments)-1])
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		if verb != "" {
+			return nil, p.errorf("end of template")
+		}
+		return nil, p.errorf(`"/", ":" or end of template`)
+	}
+	return &HttpRulePathTemplate{Segments: segments, Verb: verb}, nil
+}
+
+// pathTemplateParser is a recursive descent parser of path templates.
+type pathTemplateParser struct {
+	template       string
+	pos            int
+	doubleWildcard bool // doubleWildcard reports whether "**" has been parsed.
+}
 
-func parsePathTemplateSegments(pathPattern string) (segments []*HttpRulePathTemplateSegment) {
-	for pathPattern != "" {
-		pathPattern = strings.TrimPrefix(pathPattern, "/") // skip leading slash
+func (p *pathTemplateParser) eof() bool {
+	return p.pos >= len(p.template)
+}
+
+func (p *pathTemplateParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.template[p.pos]
+}
 
-		if m := regexDoubleAsterisk.FindString(pathPattern); m != "" {
-			segments = append(segments, &HttpRulePathTemplateSegment{Value: m})
-			pathPattern = strings.TrimPrefix(pathPattern, m)
-			continue
+func (p *pathTemplateParser) errorf(expected string) error {
+	return &PathTemplateError{Template: p.template, Offset: p.pos, Expected: expected}
+}
+
+func (p *pathTemplateParser) expect(c byte, expected string) error {
+	if p.peek() != c || p.eof() {
+		return p.errorf(expected)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *pathTemplateParser) parseSegments(inVariable bool) ([]*HttpRulePathTemplateSegment, error) {
+	segments := []*HttpRulePathTemplateSegment{}
+	for {
+		segment, err := p.parseSegment(inVariable)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+		if p.eof() || p.peek() != '/' {
+			return segments, nil
 		}
-		if m := regexSingleAsterisk.FindString(pathPattern); m != "" {
-			segments = append(segments, &HttpRulePathTemplateSegment{Value: m})
-			pathPattern = strings.TrimPrefix(pathPattern, m)
-			continue
+		if p.doubleWildcard {
+			return nil, p.errorf(`end of path after "**"`)
 		}
-		if m := regexLiteral.FindString(pathPattern); m != "" {
-			segments = append(segments, &HttpRulePathTemplateSegment{Value: m})
-			pathPattern = strings.TrimPrefix(pathPattern, m)
-			continue
+		p.pos++ // skip '/'
+	}
+}
+
+func (p *pathTemplateParser) parseSegment(inVariable bool) (*HttpRulePathTemplateSegment, error) {
+	start := p.pos
+	switch c := p.peek(); {
+	case p.eof():
+	case c == '*':
+		p.pos++
+		if p.peek() == '*' && !p.eof() {
+			p.pos++
+			p.doubleWildcard = true
 		}
-		if m := regexVariable.FindString(pathPattern); m != "" {
-			l, r, cut := strings.Cut(m[1:len(m)-1] /* trim '{' and '}' */, "=")
-			if !cut {
-				r = "*"
-			}
-
-			segments = append(segments, &HttpRulePathTemplateSegment{
-				Value: m,
-				Variable: &HttpRulePathTemplateVariable{
-					FieldPath: strings.Split(l, "."),
-					Segments:  parsePathTemplateSegments(r),
-				},
-			})
-			pathPattern = strings.TrimPrefix(pathPattern, m)
-			continue
+		return &HttpRulePathTemplateSegment{Value: p.template[start:p.pos]}, nil
+	case c == '{' && !inVariable:
+		return p.parseVariable()
+	case isPathLiteralChar(c):
+		for !p.eof() && isPathLiteralChar(p.peek()) {
+			p.pos++
 		}
+		return &HttpRulePathTemplateSegment{Value: p.template[start:p.pos]}, nil
+	}
+	if inVariable {
+		return nil, p.errorf(`a literal, "*" or "**"`)
+	}
+	return nil, p.errorf(`a literal, "*", "**" or a variable`)
+}
 
-		panic("invalid path template syntax: " + pathPattern)
+// parseVerb parses the verb following the last segment, which is glued to the segment if it is a literal.
+func (p *pathTemplateParser) parseVerb(last *HttpRulePathTemplateSegment) (string, error) {
+	if last.Variable == nil && isPathLiteralChar(last.Value[0]) {
+		i := strings.LastIndexByte(last.Value, ':')
+		if i < 0 {
+			return "", nil
+		}
+		verb := last.Value[i+1:]
+		if i == 0 {
+			p.pos -= len(last.Value)
+			return "", p.errorf(`a literal, "*", "**" or a variable`)
+		}
+		if verb == "" {
+			return "", p.errorf("a verb")
+		}
+		last.Value = last.Value[:i]
+		return verb, nil
 	}
-	return segments
+
+	if p.peek() != ':' || p.eof() {
+		return "", nil
+	}
+	p.pos++ // skip ':'
+	start := p.pos
+	for !p.eof() && isPathLiteralChar(p.peek()) && p.peek() != ':' {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("a verb")
+	}
+	return p.template[start:p.pos], nil
+}
+
+func (p *pathTemplateParser) parseVariable() (*HttpRulePathTemplateSegment, error) {
+	start := p.pos
+	p.pos++ // skip '{'
+	fieldPath := []string{}
+	for {
+		identStart := p.pos
+		for !p.eof() && isPathIdentChar(p.peek(), p.pos == identStart) {
+			p.pos++
+		}
+		if p.pos == identStart {
+			return nil, p.errorf("a field name")
+		}
+		fieldPath = append(fieldPath, p.template[identStart:p.pos])
+		if p.peek() != '.' || p.eof() {
+			break
+		}
+		p.pos++ // skip '.'
+	}
+
+	segments := []*HttpRulePathTemplateSegment{{Value: "*"}}
+	if p.peek() == '=' && !p.eof() {
+		p.pos++ // skip '='
+		var err error
+		if segments, err = p.parseSegments(true); err != nil {
+			return nil, err
+		}
+		if err := p.expect('}', `"/" or "}"`); err != nil {
+			return nil, err
+		}
+	} else if err := p.expect('}', `".", "=" or "}"`); err != nil {
+		return nil, err
+	}
+
+	return &HttpRulePathTemplateSegment{
+		Value: p.template[start:p.pos],
+		Variable: &HttpRulePathTemplateVariable{
+			FieldPath: fieldPath,
+			Segments:  segments,
+		},
+	}, nil
+}
+
+// isPathLiteralChar reports whether c can be a part of a literal segment.
+func isPathLiteralChar(c byte) bool {
+	return !strings.ContainsRune("/{}*=", rune(c))
+}
+
+// isPathIdentChar reports whether c can be a part of an identifier, where the first character must not be a digit.
+func isPathIdentChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
 }
 
 type HttpRulePathTemplate struct {
 	Segments []*HttpRulePathTemplateSegment
+	Verb     string // Verb is the custom verb following the last segment without the leading ":", or empty if the template has no verb.
 }
 type HttpRulePathTemplateSegment struct {
 	Value    string
@@ -2,6 +2,7 @@ package protocplugin
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/genproto/googleapis/api/annotations"
 	"testing"
 )
@@ -103,8 +104,8 @@ func TestHttpRule_PathTemplate(t *testing.T) {
 			},
 		},
 		{
-			name: "Get(/v1/messages/{message_id}/subs/{sub.subfield=/sub/**})",
-			sut:  HttpRule{HttpRule: &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/messages/{message_id}/subs/{sub.subfield=/sub/**}"}}},
+			name: "Get(/v1/messages/{message_id}/subs/{sub.subfield=sub/**})",
+			sut:  HttpRule{HttpRule: &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/messages/{message_id}/subs/{sub.subfield=sub/**}"}}},
 			want: &HttpRulePathTemplate{Segments: []*HttpRulePathTemplateSegment{
 				{Value: "v1"},
 				{Value: "messages"},
@@ -113,18 +114,229 @@ func TestHttpRule_PathTemplate(t *testing.T) {
 					Segments:  []*HttpRulePathTemplateSegment{{Value: "*"}},
 				}},
 				{Value: "subs"},
-				{Value: "{sub.subfield=/sub/**}", Variable: &HttpRulePathTemplateVariable{
+				{Value: "{sub.subfield=sub/**}", Variable: &HttpRulePathTemplateVariable{
 					FieldPath: []string{"sub", "subfield"},
 					Segments:  []*HttpRulePathTemplateSegment{{Value: "sub"}, {Value: "**"}},
 				}},
 			},
 			},
 		},
+		{
+			name: "Post(/v1/{name=messages/*}:undelete)",
+			sut:  HttpRule{HttpRule: &annotations.HttpRule{Pattern: &annotations.HttpRule_Post{Post: "/v1/{name=messages/*}:undelete"}}},
+			want: &HttpRulePathTemplate{Segments: []*HttpRulePathTemplateSegment{
+				{Value: "v1"},
+				{Value: "{name=messages/*}", Variable: &HttpRulePathTemplateVariable{
+					FieldPath: []string{"name"},
+					Segments:  []*HttpRulePathTemplateSegment{{Value: "messages"}, {Value: "*"}},
+				}},
+			},
+				Verb: "undelete",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := tt.sut.PathTemplate()
+			got, err := tt.sut.PathTemplate()
+			require.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func TestHttpRule_PathTemplate_NoPattern(t *testing.T) {
+	got, err := (&HttpRule{HttpRule: &annotations.HttpRule{}}).PathTemplate()
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestHttpRule_PathTemplate_Error(t *testing.T) {
+	sut := HttpRule{HttpRule: &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/{name"}}}
+	got, err := sut.PathTemplate()
+	assert.Nil(t, got)
+	var pathTemplateErr *PathTemplateError
+	require.ErrorAs(t, err, &pathTemplateErr)
+	assert.Equal(t, &PathTemplateError{Template: "/v1/{name", Offset: 9, Expected: `".", "=" or "}"`}, pathTemplateErr)
+}
+
+func TestParsePathTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     *HttpRulePathTemplate
+	}{
+		{
+			template: "/v1/*/**",
+			want:     &HttpRulePathTemplate{Segments: []*HttpRulePathTemplateSegment{{Value: "v1"}, {Value: "*"}, {Value: "**"}}},
+		},
+		{
+			template: "/v1/{name=projects/*/locations/**}",
+			want: &HttpRulePathTemplate{Segments: []*HttpRulePathTemplateSegment{
+				{Value: "v1"},
+				{Value: "{name=projects/*/locations/**}", Variable: &HttpRulePathTemplateVariable{
+					FieldPath: []string{"name"},
+					Segments:  []*HttpRulePathTemplateSegment{{Value: "projects"}, {Value: "*"}, {Value: "locations"}, {Value: "**"}},
+				}},
+			}},
+		},
+		{
+			template: "/v1/{name=projects/*}:undelete",
+			want: &HttpRulePathTemplate{
+				Segments: []*HttpRulePathTemplateSegment{
+					{Value: "v1"},
+					{Value: "{name=projects/*}", Variable: &HttpRulePathTemplateVariable{
+						FieldPath: []string{"name"},
+						Segments:  []*HttpRulePathTemplateSegment{{Value: "projects"}, {Value: "*"}},
+					}},
+				},
+				Verb: "undelete",
+			},
+		},
+		{
+			template: "/v1/messages:batchGet",
+			want:     &HttpRulePathTemplate{Segments: []*HttpRulePathTemplateSegment{{Value: "v1"}, {Value: "messages"}}, Verb: "batchGet"},
+		},
+		{
+			template: "/v1/a:b/c:d:verb",
+			want:     &HttpRulePathTemplate{Segments: []*HttpRulePathTemplateSegment{{Value: "v1"}, {Value: "a:b"}, {Value: "c:d"}}, Verb: "verb"},
+		},
+		{
+			template: "/v1/{name=a:b/*}/*:verb",
+			want: &HttpRulePathTemplate{
+				Segments: []*HttpRulePathTemplateSegment{
+					{Value: "v1"},
+					{Value: "{name=a:b/*}", Variable: &HttpRulePathTemplateVariable{
+						FieldPath: []string{"name"},
+						Segments:  []*HttpRulePathTemplateSegment{{Value: "a:b"}, {Value: "*"}},
+					}},
+					{Value: "*"},
+				},
+				Verb: "verb",
+			},
+		},
+		{
+			template: "/v1/**:verb",
+			want:     &HttpRulePathTemplate{Segments: []*HttpRulePathTemplateSegment{{Value: "v1"}, {Value: "**"}}, Verb: "verb"},
+		},
+		{
+			template: "/v1/{a_1.b2}/x-y.z~_%20",
+			want: &HttpRulePathTemplate{Segments: []*HttpRulePathTemplateSegment{
+				{Value: "v1"},
+				{Value: "{a_1.b2}", Variable: &HttpRulePathTemplateVariable{
+					FieldPath: []string{"a_1", "b2"},
+					Segments:  []*HttpRulePathTemplateSegment{{Value: "*"}},
+				}},
+				{Value: "x-y.z~_%20"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := ParsePathTemplate(tt.template)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePathTemplate_Error(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{
+			template: "",
+			want:     `invalid path template "": expected "/" at offset 0, found end of template`,
+		},
+		{
+			template: "v1/messages",
+			want:     `invalid path template "v1/messages": expected "/" at offset 0, found 'v'`,
+		},
+		{
+			template: "/",
+			want:     `invalid path template "/": expected a literal, "*", "**" or a variable at offset 1, found end of template`,
+		},
+		{
+			template: "/v1//messages",
+			want:     `invalid path template "/v1//messages": expected a literal, "*", "**" or a variable at offset 4, found '/'`,
+		},
+		{
+			template: "/v1/",
+			want:     `invalid path template "/v1/": expected a literal, "*", "**" or a variable at offset 4, found end of template`,
+		},
+		{
+			template: "/v1/{name}:",
+			want:     `invalid path template "/v1/{name}:": expected a verb at offset 11, found end of template`,
+		},
+		{
+			template: "/v1/messages:",
+			want:     `invalid path template "/v1/messages:": expected a verb at offset 13, found end of template`,
+		},
+		{
+			template: "/v1/:undelete",
+			want:     `invalid path template "/v1/:undelete": expected a literal, "*", "**" or a variable at offset 4, found ':'`,
+		},
+		{
+			template: "/v1/*:a:b",
+			want:     `invalid path template "/v1/*:a:b": expected end of template at offset 7, found ':'`,
+		},
+		{
+			template: "/v1/*:a/b",
+			want:     `invalid path template "/v1/*:a/b": expected end of template at offset 7, found '/'`,
+		},
+		{
+			template: "/v1/a:b*",
+			want:     `invalid path template "/v1/a:b*": expected end of template at offset 7, found '*'`,
+		},
+		{
+			template: "/v1/a*",
+			want:     `invalid path template "/v1/a*": expected "/", ":" or end of template at offset 5, found '*'`,
+		},
+		{
+			template: "/v1/**/messages",
+			want:     `invalid path template "/v1/**/messages": expected end of path after "**" at offset 6, found '/'`,
+		},
+		{
+			template: "/v1/{name=**}/messages",
+			want:     `invalid path template "/v1/{name=**}/messages": expected end of path after "**" at offset 13, found '/'`,
+		},
+		{
+			template: "/v1/{name",
+			want:     `invalid path template "/v1/{name": expected ".", "=" or "}" at offset 9, found end of template`,
+		},
+		{
+			template: "/v1/{}",
+			want:     `invalid path template "/v1/{}": expected a field name at offset 5, found '}'`,
+		},
+		{
+			template: "/v1/{1st}",
+			want:     `invalid path template "/v1/{1st}": expected a field name at offset 5, found '1'`,
+		},
+		{
+			template: "/v1/{a.}",
+			want:     `invalid path template "/v1/{a.}": expected a field name at offset 7, found '}'`,
+		},
+		{
+			template: "/v1/{name=messages/*",
+			want:     `invalid path template "/v1/{name=messages/*": expected "/" or "}" at offset 20, found end of template`,
+		},
+		{
+			template: "/v1/{name=/messages/*}",
+			want:     `invalid path template "/v1/{name=/messages/*}": expected a literal, "*" or "**" at offset 10, found '/'`,
+		},
+		{
+			template: "/v1/{name={id}}",
+			want:     `invalid path template "/v1/{name={id}}": expected a literal, "*" or "**" at offset 10, found '{'`,
+		},
+		{
+			template: "/v1/}",
+			want:     `invalid path template "/v1/}": expected a literal, "*", "**" or a variable at offset 4, found '}'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := ParsePathTemplate(tt.template)
+			assert.Nil(t, got)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
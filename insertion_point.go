@@ -0,0 +1,77 @@
+package protocplugin
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// InsertionPointMarker returns a line declaring the insertion point with the given name in the generated file with the given name.
+// The line is a comment in the language of the file, determined by the file extension, e.g. "// @@protoc_insertion_point(name)" for a .go file and "# @@protoc_insertion_point(name)" for a .py file.
+// The returned line does not end with a newline.
+func InsertionPointMarker(fileName, name string) string {
+	begin, end := commentSyntax(fileName)
+	marker := begin + " @@protoc_insertion_point(" + name + ")"
+	if end != "" {
+		marker += " " + end
+	}
+	return marker
+}
+
+var commentSyntaxes = map[string][2]string{}
+
+func init() {
+	for delimiters, extensions := range map[[2]string][]string{
+		{"//", ""}:      {".go", ".java", ".kt", ".kts", ".scala", ".groovy", ".c", ".h", ".cc", ".cpp", ".cxx", ".hh", ".hpp", ".m", ".mm", ".cs", ".swift", ".js", ".mjs", ".cjs", ".jsx", ".ts", ".mts", ".cts", ".tsx", ".dart", ".rs", ".php", ".zig", ".proto"},
+		{"#", ""}:       {".py", ".pyi", ".rb", ".rbs", ".sh", ".bash", ".zsh", ".pl", ".r", ".jl", ".ex", ".exs", ".nim", ".yaml", ".yml", ".toml", ".tf", ".cmake", ".mk"},
+		{"--", ""}:      {".sql", ".lua", ".hs", ".elm"},
+		{";;", ""}:      {".clj", ".cljs", ".el", ".lisp", ".scm"},
+		{"%", ""}:       {".erl", ".hrl", ".tex"},
+		{"/*", "*/"}:    {".css"},
+		{"<!--", "-->"}: {".html", ".htm", ".xhtml", ".xml", ".svg", ".vue", ".md"},
+	} {
+		for _, ext := range extensions {
+			commentSyntaxes[ext] = delimiters
+		}
+	}
+}
+
+// commentSyntax returns the delimiters of a comment in the language of the file, determined by the file extension.
+// end is empty if the comment continues until the end of the line. "//" is returned for an unknown extension.
+func commentSyntax(fileName string) (begin, end string) {
+	if delimiters, ok := commentSyntaxes[strings.ToLower(path.Ext(fileName))]; ok {
+		return delimiters[0], delimiters[1]
+	}
+	return "//", ""
+}
+
+// ApplyInsertion returns the content of the file f.Name with f.Content inserted at the insertion point f.InsertionPoint in the same way as protoc.
+// That is, f.Content is inserted immediately above the line declaring the insertion point, and each of its lines is indented as the line.
+// content is the content of the file f.Name, and an error is returned if it does not declare the insertion point.
+func ApplyInsertion(content string, f *GeneratedFile) (string, error) {
+	marker := "@@protoc_insertion_point(" + f.InsertionPoint + ")"
+	i := strings.Index(content, marker)
+	if i < 0 {
+		return "", fmt.Errorf("%s: insertion point %q not found", f.Name, f.InsertionPoint)
+	}
+	lineBegin := strings.LastIndex(content[:i], "\n") + 1
+	line := content[lineBegin:i]
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+	b := strings.Builder{}
+	b.WriteString(content[:lineBegin])
+	for _, inserted := range strings.SplitAfter(f.Content, "\n") {
+		if inserted == "" {
+			continue
+		}
+		if inserted != "\n" {
+			b.WriteString(indent)
+		}
+		b.WriteString(inserted)
+	}
+	if f.Content != "" && !strings.HasSuffix(f.Content, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(content[lineBegin:])
+	return b.String(), nil
+}
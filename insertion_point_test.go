@@ -0,0 +1,85 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+func TestInsertionPointMarker(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{fileName: "foo/bar.pb.go", want: "// @@protoc_insertion_point(imports)"},
+		{fileName: "foo/bar_pb2.py", want: "# @@protoc_insertion_point(imports)"},
+		{fileName: "foo/bar.sql", want: "-- @@protoc_insertion_point(imports)"},
+		{fileName: "foo/bar.CSS", want: "/* @@protoc_insertion_point(imports) */"},
+		{fileName: "foo/bar.html", want: "<!-- @@protoc_insertion_point(imports) -->"},
+		{fileName: "foo/bar", want: "// @@protoc_insertion_point(imports)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			assert.Equal(t, tt.want, protocplugin.InsertionPointMarker(tt.fileName, "imports"))
+		})
+	}
+}
+
+func TestRun_InsertionPoint(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		return []*protocplugin.GeneratedFile{
+			{Name: "dep.txt", Content: "hello\n" + protocplugin.InsertionPointMarker("dep.txt", "end") + "\n"},
+			{Name: "dep.pb.go", Content: "// inserted\n", InsertionPoint: "imports"},
+		}, nil
+	}
+	resp := runPlugin(t, handle, testFileDep)
+	require.Len(t, resp.File, 2)
+	assert.Equal(t, "dep.txt", resp.File[0].GetName())
+	assert.Nil(t, resp.File[0].InsertionPoint)
+	assert.Equal(t, "hello\n// @@protoc_insertion_point(end)\n", resp.File[0].GetContent())
+	assert.Equal(t, "dep.pb.go", resp.File[1].GetName())
+	assert.Equal(t, "imports", resp.File[1].GetInsertionPoint())
+	assert.Equal(t, "// inserted\n", resp.File[1].GetContent())
+}
+
+func TestApplyInsertion(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		file    *protocplugin.GeneratedFile
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "top level",
+			content: "package a\n// @@protoc_insertion_point(imports)\n",
+			file:    &protocplugin.GeneratedFile{Name: "a.go", Content: "import \"fmt\"\n", InsertionPoint: "imports"},
+			want:    "package a\nimport \"fmt\"\n// @@protoc_insertion_point(imports)\n",
+		},
+		{
+			name:    "indented",
+			content: "type A struct {\n\t// @@protoc_insertion_point(fields)\n}\n",
+			file:    &protocplugin.GeneratedFile{Name: "a.go", Content: "X int\n\nY int", InsertionPoint: "fields"},
+			want:    "type A struct {\n\tX int\n\n\tY int\n\t// @@protoc_insertion_point(fields)\n}\n",
+		},
+		{
+			name:    "not found",
+			content: "package a\n",
+			file:    &protocplugin.GeneratedFile{Name: "a.go", Content: "x", InsertionPoint: "imports"},
+			wantErr: `a.go: insertion point "imports" not found`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protocplugin.ApplyInsertion(tt.content, tt.file)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package protocplugin
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Location represents the position of an element in a .proto source file.
+// The position is available only if the request contains the source code info of the file.
+type Location struct {
+	File      string                  // File is the path of the .proto file.
+	Path      protoreflect.SourcePath // Path is the path to the element in the file descriptor, which is empty for a file.
+	Line      int                     // Line is the 1-based line number where the element starts, or 0 if unknown.
+	Column    int                     // Column is the 1-based column number where the element starts, or 0 if unknown.
+	EndLine   int                     // EndLine is the 1-based line number where the element ends, or 0 if unknown.
+	EndColumn int                     // EndColumn is the 1-based column number just after the end of the element, or 0 if unknown.
+}
+
+// IsKnown reports whether the position of the element in the source file is known.
+func (l Location) IsKnown() bool {
+	return l.Line > 0
+}
+
+// String returns the location in the form "file:line:column", or only the file path if the position is unknown.
+func (l Location) String() string {
+	if !l.IsKnown() {
+		return l.File
+	}
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
+// sourceLocations indexes the source locations of a file by their paths.
+type sourceLocations struct {
+	file      string
+	locations map[string]protoreflect.SourceLocation
+}
+
+func newSourceLocations(f protoreflect.FileDescriptor) *sourceLocations {
+	s := &sourceLocations{file: f.Path(), locations: map[string]protoreflect.SourceLocation{}}
+	locs := f.SourceLocations()
+	for i := 0; i < locs.Len(); i++ {
+		loc := locs.Get(i)
+		key := loc.Path.String()
+		if _, ok := s.locations[key]; !ok {
+			s.locations[key] = loc
+		}
+	}
+	return s
+}
+
+// location returns the location of the element at the given path.
+func (s *sourceLocations) location(path protoreflect.SourcePath) Location {
+	l := Location{File: s.file, Path: path}
+	if loc, ok := s.locations[path.String()]; ok {
+		// SourceLocation is 0-based.
+		l.Line, l.Column = loc.StartLine+1, loc.StartColumn+1
+		l.EndLine, l.EndColumn = loc.EndLine+1, loc.EndColumn+1
+	}
+	return l
+}
@@ -0,0 +1,57 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+func TestLocation_String(t *testing.T) {
+	tests := []struct {
+		name string
+		sut  protocplugin.Location
+		want string
+	}{
+		{
+			name: "known",
+			sut:  protocplugin.Location{File: "foo.proto", Line: 12, Column: 3, EndLine: 12, EndColumn: 10},
+			want: "foo.proto:12:3",
+		},
+		{
+			name: "unknown",
+			sut:  protocplugin.Location{File: "foo.proto", Path: protoreflect.SourcePath{4, 0}},
+			want: "foo.proto",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.sut.String())
+		})
+	}
+}
+
+func TestRun_Locations(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		file := files["oneof.proto"]
+		message := file.Messages[0]
+
+		assert.Equal(t, protocplugin.Location{
+			File:      "oneof.proto",
+			Path:      protoreflect.SourcePath{4, 0, 8, 0},
+			Line:      6,
+			Column:    3,
+			EndLine:   9,
+			EndColumn: 4,
+		}, message.Oneofs[0].Location)
+		assert.Equal(t, message.Oneofs[0].Location, protocplugin.LocationOf(message.Oneofs[0]))
+
+		assert.Equal(t, protocplugin.Location{File: "oneof.proto", Path: protoreflect.SourcePath{4, 0, 2, 1}}, message.Fields[1].Location)
+		assert.False(t, message.Fields[1].Location.IsKnown())
+		assert.Equal(t, protocplugin.Location{File: "oneof.proto"}, file.Location)
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileOneof)
+	assert.Empty(t, resp.GetError())
+}
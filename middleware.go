@@ -0,0 +1,185 @@
+package protocplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"google.golang.org/protobuf/types/pluginpb"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Middleware is a function type that wraps a ContextPluginHandler to add processing before or after it.
+// Middlewares can be applied to a handler of any entry point including Run by WithMiddlewares.
+type Middleware func(next ContextPluginHandler) ContextPluginHandler
+
+// WithMiddlewares wraps the handler with the middlewares by Chain.
+func WithMiddlewares(middlewares ...Middleware) Option {
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// Chain returns the handler wrapped by the middlewares, where the first middleware is the outermost.
+// That is, the files generated by the handler are processed by the last middleware first.
+func Chain(handle ContextPluginHandler, middlewares ...Middleware) ContextPluginHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handle = middlewares[i](handle)
+	}
+	return handle
+}
+
+// mapFiles returns a middleware which applies fn to each generated file.
+func mapFiles(fn func(f *GeneratedFile) error) Middleware {
+	return func(next ContextPluginHandler) ContextPluginHandler {
+		return func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error) {
+			outFiles, err := next(ctx, req, files, registry, diags)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range outFiles {
+				if err := fn(f); err != nil {
+					return nil, err
+				}
+			}
+			return outFiles, nil
+		}
+	}
+}
+
+// LicenseHeader returns a middleware which prepends the header to each generated file as comments in the language of the file.
+// Each line of the header becomes a line comment, and the annotations of the file are shifted accordingly.
+// Files to be inserted at insertion points are not changed.
+func LicenseHeader(header string) Middleware {
+	return mapFiles(func(f *GeneratedFile) error {
+		if f.InsertionPoint != "" {
+			return nil
+		}
+		begin, end := commentSyntax(f.Name)
+		b := strings.Builder{}
+		for _, line := range strings.Split(strings.TrimRight(header, "\n"), "\n") {
+			comment := strings.TrimRight(begin+" "+line, " ")
+			if end != "" {
+				comment += " " + end
+			}
+			b.WriteString(comment + "\n")
+		}
+		b.WriteString("\n")
+		f.Content = b.String() + f.Content
+		for _, a := range f.Annotations {
+			a.Begin += b.Len()
+			a.End += b.Len()
+		}
+		return nil
+	})
+}
+
+// Format returns a middleware which formats each generated file whose name has the given extension such as ".go".
+// Files to be inserted at insertion points are not formatted, and formatting a file with annotations fails because their byte ranges would be invalidated.
+func Format(extension string, formatter func(content string) (string, error)) Middleware {
+	return mapFiles(func(f *GeneratedFile) error {
+		if f.InsertionPoint != "" || path.Ext(f.Name) != extension {
+			return nil
+		}
+		if len(f.Annotations) > 0 {
+			return fmt.Errorf("%s: cannot format a file with annotations", f.Name)
+		}
+		formatted, err := formatter(f.Content)
+		if err != nil {
+			return fmt.Errorf("%s: failed to format: %w", f.Name, err)
+		}
+		f.Content = formatted
+		return nil
+	})
+}
+
+// GoFormat returns a middleware which formats each generated .go file in the same way as gofmt.
+func GoFormat() Middleware {
+	return Format(".go", func(content string) (string, error) {
+		formatted, err := format.Source([]byte(content))
+		return string(formatted), err
+	})
+}
+
+// FilterFiles returns a middleware which keeps only the generated files for which keep returns true.
+func FilterFiles(keep func(f *GeneratedFile) bool) Middleware {
+	return func(next ContextPluginHandler) ContextPluginHandler {
+		return func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error) {
+			outFiles, err := next(ctx, req, files, registry, diags)
+			if err != nil {
+				return nil, err
+			}
+			kept := []*GeneratedFile{}
+			for _, f := range outFiles {
+				if keep(f) {
+					kept = append(kept, f)
+				}
+			}
+			return kept, nil
+		}
+	}
+}
+
+// ValidateFileNames returns a middleware which checks that the names of the generated files are acceptable to protoc,
+// that is they are non-empty, clean, relative and slash-separated paths within the output directory, and the created files have distinct names.
+func ValidateFileNames() Middleware {
+	return func(next ContextPluginHandler) ContextPluginHandler {
+		return func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error) {
+			outFiles, err := next(ctx, req, files, registry, diags)
+			if err != nil {
+				return nil, err
+			}
+			errs := []error{}
+			created := map[string]bool{}
+			for _, f := range outFiles {
+				switch {
+				case f.Name == "":
+					errs = append(errs, fmt.Errorf("generated file name is empty"))
+				case strings.Contains(f.Name, `\`):
+					errs = append(errs, fmt.Errorf("generated file name %q must be separated by slashes", f.Name))
+				case path.IsAbs(f.Name):
+					errs = append(errs, fmt.Errorf("generated file name %q must be relative", f.Name))
+				case path.Clean(f.Name) != f.Name:
+					errs = append(errs, fmt.Errorf("generated file name %q must be clean as %q", f.Name, path.Clean(f.Name)))
+				case f.Name == ".." || strings.HasPrefix(f.Name, "../"):
+					errs = append(errs, fmt.Errorf("generated file name %q must be within the output directory", f.Name))
+				case f.InsertionPoint == "" && created[f.Name]:
+					errs = append(errs, fmt.Errorf("generated file name %q is duplicated", f.Name))
+				}
+				if f.InsertionPoint == "" {
+					created[f.Name] = true
+				}
+			}
+			if err := errors.Join(errs...); err != nil {
+				return nil, err
+			}
+			return outFiles, nil
+		}
+	}
+}
+
+// LogFiles returns a middleware which writes the names and the sizes of the generated files and the elapsed time to w.
+func LogFiles(w io.Writer) Middleware {
+	return func(next ContextPluginHandler) ContextPluginHandler {
+		return func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error) {
+			start := time.Now()
+			outFiles, err := next(ctx, req, files, registry, diags)
+			if err != nil {
+				fmt.Fprintf(w, "failed to generate files in %v: %v\n", time.Since(start), err)
+				return nil, err
+			}
+			for _, f := range outFiles {
+				if f.InsertionPoint != "" {
+					fmt.Fprintf(w, "generated %s@%s (%d bytes)\n", f.Name, f.InsertionPoint, len(f.Content))
+				} else {
+					fmt.Fprintf(w, "generated %s (%d bytes)\n", f.Name, len(f.Content))
+				}
+			}
+			fmt.Fprintf(w, "generated %d files in %v\n", len(outFiles), time.Since(start))
+			return outFiles, nil
+		}
+	}
+}
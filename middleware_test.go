@@ -0,0 +1,272 @@
+package protocplugin_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/pluginpb"
+	"strings"
+	"testing"
+)
+
+// returnFiles returns a handler which returns the files.
+func returnFiles(files ...*protocplugin.GeneratedFile) protocplugin.PluginHandler {
+	return func(*pluginpb.CodeGeneratorRequest, map[string]*protocplugin.File, *protocplugin.Registry, *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		return files, nil
+	}
+}
+
+// runMiddlewares runs the handler with the middlewares through Run with testFileDep.
+func runMiddlewares(t *testing.T, handle protocplugin.PluginHandler, middlewares ...protocplugin.Middleware) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	return runRequest(t, newRequest(t, "", testFileDep), handle, protocplugin.WithMiddlewares(middlewares...))
+}
+
+// appendSuffix returns a middleware which appends the suffix to the content of each file.
+func appendSuffix(suffix string) protocplugin.Middleware {
+	return func(next protocplugin.ContextPluginHandler) protocplugin.ContextPluginHandler {
+		return func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+			outFiles, err := next(ctx, req, files, registry, diags)
+			for _, f := range outFiles {
+				f.Content += suffix
+			}
+			return outFiles, err
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	handle := protocplugin.Chain(func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		return []*protocplugin.GeneratedFile{{Name: "a.txt", Content: "x"}}, nil
+	}, appendSuffix("1"), appendSuffix("2"), appendSuffix("3"))
+
+	resp := runRequestContext(t, context.Background(), newRequest(t, "", testFileDep), handle)
+
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.File, 1)
+	assert.Equal(t, "x321", resp.File[0].GetContent())
+}
+
+func TestWithMiddlewares(t *testing.T) {
+	resp := runRequest(t, newRequest(t, "", testFileDep), returnFiles(&protocplugin.GeneratedFile{Name: "a.txt", Content: "x"}),
+		protocplugin.WithMiddlewares(appendSuffix("1"), appendSuffix("2")), protocplugin.WithMiddlewares(appendSuffix("3")))
+
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.File, 1)
+	assert.Equal(t, "x321", resp.File[0].GetContent())
+}
+
+func TestLicenseHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		file *protocplugin.GeneratedFile
+		want string
+	}{
+		{
+			name: "go",
+			file: &protocplugin.GeneratedFile{Name: "a.go", Content: "package a\n"},
+			want: "// Copyright 2024 Example\n// SPDX-License-Identifier: MIT\n\npackage a\n",
+		},
+		{
+			name: "python",
+			file: &protocplugin.GeneratedFile{Name: "a.py", Content: "import os\n"},
+			want: "# Copyright 2024 Example\n# SPDX-License-Identifier: MIT\n\nimport os\n",
+		},
+		{
+			name: "css",
+			file: &protocplugin.GeneratedFile{Name: "a.css", Content: "a {}\n"},
+			want: "/* Copyright 2024 Example */\n/* SPDX-License-Identifier: MIT */\n\na {}\n",
+		},
+		{
+			name: "insertion point",
+			file: &protocplugin.GeneratedFile{Name: "a.go", Content: "var x = 1\n", InsertionPoint: "imports"},
+			want: "var x = 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := runMiddlewares(t, returnFiles(tt.file),
+				protocplugin.LicenseHeader("Copyright 2024 Example\nSPDX-License-Identifier: MIT\n"))
+
+			require.Nil(t, resp.Error)
+			require.Len(t, resp.File, 1)
+			assert.Equal(t, tt.want, resp.File[0].GetContent())
+		})
+	}
+}
+
+func TestLicenseHeader_ShiftsAnnotations(t *testing.T) {
+	resp := runMiddlewares(t, func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		message, err := registry.Message("dep.Shared")
+		if err != nil {
+			return nil, err
+		}
+		f := &protocplugin.GeneratedFile{Name: "a.go", Content: "type "}
+		f.AppendAnnotated(message, "Shared")
+		return []*protocplugin.GeneratedFile{f}, nil
+	}, protocplugin.LicenseHeader("MIT"))
+
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.File, 1)
+	content := resp.File[0].GetContent()
+	annotations := resp.File[0].GetGeneratedCodeInfo().GetAnnotation()
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "Shared", content[annotations[0].GetBegin():annotations[0].GetEnd()])
+}
+
+func TestGoFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    *protocplugin.GeneratedFile
+		want    string
+		wantErr string
+	}{
+		{
+			name: "go",
+			file: &protocplugin.GeneratedFile{Name: "a.go", Content: "package a\nfunc  F( ) {  }\n"},
+			want: "package a\n\nfunc F() {}\n",
+		},
+		{
+			name: "not go",
+			file: &protocplugin.GeneratedFile{Name: "a.txt", Content: "func  F( ) {  }"},
+			want: "func  F( ) {  }",
+		},
+		{
+			name:    "syntax error",
+			file:    &protocplugin.GeneratedFile{Name: "a.go", Content: "package a\nfunc {"},
+			wantErr: "a.go: failed to format: ",
+		},
+		{
+			name:    "annotated",
+			file:    &protocplugin.GeneratedFile{Name: "a.go", Content: "package a", Annotations: []*protocplugin.Annotation{{Begin: 0, End: 1}}},
+			wantErr: "a.go: cannot format a file with annotations",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := runMiddlewares(t, returnFiles(tt.file), protocplugin.GoFormat())
+
+			if tt.wantErr != "" {
+				assert.Contains(t, resp.GetError(), tt.wantErr)
+				assert.Empty(t, resp.File)
+				return
+			}
+			require.Nil(t, resp.Error)
+			require.Len(t, resp.File, 1)
+			assert.Equal(t, tt.want, resp.File[0].GetContent())
+		})
+	}
+}
+
+func TestFilterFiles(t *testing.T) {
+	resp := runMiddlewares(t, returnFiles(
+		&protocplugin.GeneratedFile{Name: "a.go"},
+		&protocplugin.GeneratedFile{Name: "a_test.go"},
+		&protocplugin.GeneratedFile{Name: "b.go"},
+	), protocplugin.FilterFiles(func(f *protocplugin.GeneratedFile) bool {
+		return !strings.HasSuffix(f.Name, "_test.go")
+	}))
+
+	require.Nil(t, resp.Error)
+	names := []string{}
+	for _, f := range resp.File {
+		names = append(names, f.GetName())
+	}
+	assert.Equal(t, []string{"a.go", "b.go"}, names)
+}
+
+func TestValidateFileNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []*protocplugin.GeneratedFile
+		wantErr []string
+	}{
+		{
+			name: "valid",
+			files: []*protocplugin.GeneratedFile{
+				{Name: "a.go"},
+				{Name: "dir/b.go"},
+				{Name: "dir/b.go", InsertionPoint: "imports"},
+			},
+		},
+		{
+			name:    "empty",
+			files:   []*protocplugin.GeneratedFile{{Name: ""}},
+			wantErr: []string{`generated file name is empty`},
+		},
+		{
+			name:    "backslash",
+			files:   []*protocplugin.GeneratedFile{{Name: `dir\a.go`}},
+			wantErr: []string{`generated file name "dir\\a.go" must be separated by slashes`},
+		},
+		{
+			name:    "absolute",
+			files:   []*protocplugin.GeneratedFile{{Name: "/a.go"}},
+			wantErr: []string{`generated file name "/a.go" must be relative`},
+		},
+		{
+			name:    "not clean",
+			files:   []*protocplugin.GeneratedFile{{Name: "dir/../a.go"}},
+			wantErr: []string{`generated file name "dir/../a.go" must be clean as "a.go"`},
+		},
+		{
+			name:    "outside",
+			files:   []*protocplugin.GeneratedFile{{Name: "../a.go"}},
+			wantErr: []string{`generated file name "../a.go" must be within the output directory`},
+		},
+		{
+			name: "multiple errors",
+			files: []*protocplugin.GeneratedFile{
+				{Name: "a.go"},
+				{Name: "a.go"},
+				{Name: "./b.go"},
+			},
+			wantErr: []string{
+				`generated file name "a.go" is duplicated`,
+				`generated file name "./b.go" must be clean as "b.go"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := runMiddlewares(t, returnFiles(tt.files...), protocplugin.ValidateFileNames())
+
+			if len(tt.wantErr) > 0 {
+				for _, want := range tt.wantErr {
+					assert.Contains(t, resp.GetError(), want)
+				}
+				assert.Empty(t, resp.File)
+				return
+			}
+			require.Nil(t, resp.Error)
+			assert.Len(t, resp.File, len(tt.files))
+		})
+	}
+}
+
+func TestLogFiles(t *testing.T) {
+	log := bytes.Buffer{}
+	resp := runMiddlewares(t, returnFiles(
+		&protocplugin.GeneratedFile{Name: "a.go", Content: "package a\n"},
+		&protocplugin.GeneratedFile{Name: "a.go", Content: "var x = 1\n", InsertionPoint: "imports"},
+	), protocplugin.LogFiles(&log))
+
+	require.Nil(t, resp.Error)
+	assert.Contains(t, log.String(), "generated a.go (10 bytes)\n")
+	assert.Contains(t, log.String(), "generated a.go@imports (10 bytes)\n")
+	assert.Contains(t, log.String(), "generated 2 files in ")
+}
+
+func TestLogFiles_Error(t *testing.T) {
+	log := bytes.Buffer{}
+	resp := runMiddlewares(t, func(*pluginpb.CodeGeneratorRequest, map[string]*protocplugin.File, *protocplugin.Registry, *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		return nil, fmt.Errorf("something went wrong")
+	}, protocplugin.LogFiles(&log))
+
+	assert.Contains(t, resp.GetError(), "something went wrong")
+	assert.Contains(t, log.String(), "failed to generate files in ")
+	assert.Contains(t, log.String(), "something went wrong")
+}
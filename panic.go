@@ -0,0 +1,80 @@
+package protocplugin
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// maxTrimmedStackFrames is the maximum number of frames in a trimmed stack trace.
+const maxTrimmedStackFrames = 10
+
+// panicError is an error converted from a recovered panic.
+type panicError struct {
+	value any
+	stack string
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", e.value, e.stack)
+}
+
+// callSafely calls fn and returns the recovered panic as an error if fn panics.
+// The stack trace in the error is trimmed to the frames relevant to the panic unless fullStack is true.
+func callSafely(fullStack bool, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := string(debug.Stack())
+			if !fullStack {
+				stack = trimStack(stack)
+			}
+			err = &panicError{value: r, stack: stack}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// trimStack trims the stack trace to the frames between the panic and the call of callSafely excluding the closures of this package calling the function,
+// with at most maxTrimmedStackFrames frames.
+func trimStack(stack string) string {
+	lines := strings.Split(strings.TrimRight(stack, "\n"), "\n")
+	if len(lines) == 0 {
+		return stack
+	}
+	header, lines := lines[0], lines[1:]
+	callSafelyName := runtime.FuncForPC(reflect.ValueOf(callSafely).Pointer()).Name()
+
+	// Each frame consists of a line of the function and a line of the source position.
+	frames := []string{}
+	for i := 0; i+1 < len(lines); i += 2 {
+		function := lines[i]
+		if strings.HasPrefix(function, "panic(") {
+			frames = frames[:0] // drop the frames of the recovery
+			continue
+		}
+		if strings.HasPrefix(function, callSafelyName+"(") {
+			break
+		}
+		frames = append(frames, function+"\n"+lines[i+1])
+	}
+	// Drop the closures of this package wrapping the function passed to callSafely, keeping the frame where the panic happened.
+	packagePrefix := strings.TrimSuffix(callSafelyName, "callSafely")
+	for len(frames) > 1 && isPackageClosure(frames[len(frames)-1], packagePrefix) {
+		frames = frames[:len(frames)-1]
+	}
+	omitted := ""
+	if len(frames) > maxTrimmedStackFrames {
+		frames, omitted = frames[:maxTrimmedStackFrames], "\n\t...\n(set the parameter full_stack_trace to show the full stack trace)"
+	}
+	return header + "\n" + strings.Join(frames, "\n") + omitted
+}
+
+// isPackageClosure reports whether the frame is of an anonymous function in the package with the prefix, such as "pkg.generate.func1(...)".
+func isPackageClosure(frame, packagePrefix string) bool {
+	function, _, _ := strings.Cut(frame, "(")
+	name, ok := strings.CutPrefix(function, packagePrefix)
+	return ok && strings.Contains(name, ".func")
+}
@@ -0,0 +1,32 @@
+package protocplugin
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func panicInPackage() {
+	panic("something went wrong")
+}
+
+func TestCallSafely_PanicInPackage(t *testing.T) {
+	err := callSafely(false, func() {
+		panicInPackage()
+	})
+	require.Error(t, err)
+	got := err.Error()
+	assert.True(t, strings.HasPrefix(got, "something went wrong\n\ngoroutine "), got)
+	assert.Contains(t, got, "protoc-plugin-lib.panicInPackage(")
+	assert.NotContains(t, got, "protoc-plugin-lib.TestCallSafely_PanicInPackage.func1(")
+	assert.NotContains(t, got, "protoc-plugin-lib.callSafely(")
+}
+
+func TestCallSafely_PanicInClosure(t *testing.T) {
+	err := callSafely(false, func() {
+		panic("something went wrong")
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "protoc-plugin-lib.TestCallSafely_PanicInClosure.func1(")
+}
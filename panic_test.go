@@ -0,0 +1,41 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/pluginpb"
+	"strings"
+	"testing"
+)
+
+func panickingHandler(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+	panic("something went wrong")
+}
+
+func TestRun_Panic(t *testing.T) {
+	t.Run("trimmed stack trace", func(t *testing.T) {
+		resp := runPlugin(t, panickingHandler, testFileDep)
+		got := resp.GetError()
+		assert.True(t, strings.HasPrefix(got, "panic in the plugin handler: something went wrong\n\ngoroutine "), got)
+		assert.Contains(t, got, "protoc-plugin-lib_test.panickingHandler(")
+		assert.NotContains(t, got, "runtime/debug.Stack(")
+		assert.NotContains(t, got, "protoc-plugin-lib.Run.func")
+		assert.NotContains(t, got, "protoc-plugin-lib.callSafely(")
+		assert.Empty(t, resp.File)
+	})
+	t.Run("full stack trace", func(t *testing.T) {
+		resp := runRequest(t, newRequest(t, "full_stack_trace", testFileDep), panickingHandler)
+		got := resp.GetError()
+		assert.True(t, strings.HasPrefix(got, "panic in the plugin handler: something went wrong\n\ngoroutine "), got)
+		assert.Contains(t, got, "protoc-plugin-lib_test.panickingHandler(")
+		assert.Contains(t, got, "protoc-plugin-lib.callSafely(")
+	})
+	t.Run("full stack trace with value", func(t *testing.T) {
+		resp := runRequest(t, newRequest(t, "full_stack_trace=1", testFileDep), panickingHandler)
+		assert.Contains(t, resp.GetError(), "protoc-plugin-lib.callSafely(")
+	})
+	t.Run("invalid full stack trace", func(t *testing.T) {
+		resp := runRequest(t, newRequest(t, "full_stack_trace=yes", testFileDep), panickingHandler)
+		assert.Equal(t, `invalid parameter: invalid value "yes" for parameter "full_stack_trace": want true or false`, resp.GetError())
+	})
+}
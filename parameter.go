@@ -0,0 +1,385 @@
+package protocplugin
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// parameterEntry is an entry of a plugin parameter in the form "key=value" or "key".
+type parameterEntry struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+func (e parameterEntry) String() string {
+	if !e.HasValue {
+		return e.Key
+	}
+	return e.Key + "=" + e.Value
+}
+
+// splitParameter splits a plugin parameter in the form "key=value,flag,..." into entries.
+func splitParameter(parameter string) []parameterEntry {
+	entries := []parameterEntry{}
+	for _, s := range strings.Split(parameter, ",") {
+		if s == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(s, "=")
+		entries = append(entries, parameterEntry{Key: key, Value: value, HasValue: hasValue})
+	}
+	return entries
+}
+
+// ParseParameter parses a plugin parameter in the form "key=value,flag,..." into the struct pointed to by v.
+// Each exported field of the struct accepts the parameter named by its `param` struct tag, and its `usage` tag describes it in ParameterUsage.
+// Fields without the `param` tag are ignored. The following field types are supported:
+//   - bool: "key" sets true, and "key=true" or "key=false" sets the value.
+//   - string, signed and unsigned integers, floats and time.Duration: "key=value" sets the value.
+//   - slices of the above types except bool: each "key=value" appends the value.
+//   - maps from string to the above types except bool: each "key=k=v" puts the value v with the key k, e.g. "M=foo.proto=example.com/foo".
+//
+// All the unknown keys and invalid values in the parameter are reported in the returned error.
+func ParseParameter(parameter string, v any) error {
+	fields, err := parameterFields(v)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, e := range splitParameter(parameter) {
+		f, ok := fields[e.Key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown parameter %q", e.Key))
+			continue
+		}
+		if err := f.set(e); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for parameter %q: %w", e.Value, e.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParameterUsage returns a --help style documentation of the parameters accepted by the struct pointed to by v.
+// The current values of the fields are shown as the default values.
+func ParameterUsage(v any) (string, error) {
+	fields, err := parameterFields(v)
+	if err != nil {
+		return "", err
+	}
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return fields[names[i]].index < fields[names[j]].index })
+
+	b := strings.Builder{}
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		f := fields[name]
+		line := "  " + f.syntax() + "\t" + f.usage
+		if d := f.defaultValue(); d != "" {
+			line += " (default: " + d + ")"
+		}
+		fmt.Fprintln(w, strings.TrimRight(line, " \t"))
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+type parameterField struct {
+	name  string
+	usage string
+	index int
+	value reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func parameterFields(v any) (map[string]*parameterField, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("parameters must be a non-nil pointer to a struct: %T", v)
+	}
+	rv = rv.Elem()
+	fields := map[string]*parameterField{}
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		name, ok := sf.Tag.Lookup("param")
+		if !ok || name == "-" || !sf.IsExported() {
+			continue
+		}
+		if _, dup := fields[name]; dup {
+			return nil, fmt.Errorf("duplicate parameter %q in %T", name, v)
+		}
+		f := &parameterField{name: name, usage: sf.Tag.Get("usage"), index: i, value: rv.Field(i)}
+		if !isSupportedParameterType(sf.Type) {
+			return nil, fmt.Errorf("unsupported type of parameter %q: %v", name, sf.Type)
+		}
+		fields[name] = f
+	}
+	return fields, nil
+}
+
+func isScalarParameterType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isSupportedParameterType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool:
+		return true
+	case reflect.Slice:
+		return isScalarParameterType(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && isScalarParameterType(t.Elem())
+	}
+	return isScalarParameterType(t)
+}
+
+func (f *parameterField) set(e parameterEntry) error {
+	t := f.value.Type()
+	switch t.Kind() {
+	case reflect.Bool:
+		if !e.HasValue {
+			f.value.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(e.Value)
+		if err != nil {
+			return fmt.Errorf("want true or false")
+		}
+		f.value.SetBool(b)
+		return nil
+	case reflect.Slice:
+		elem, err := parseScalarParameter(t.Elem(), e.Value)
+		if err != nil {
+			return err
+		}
+		f.value.Set(reflect.Append(f.value, elem))
+		return nil
+	case reflect.Map:
+		key, value, ok := strings.Cut(e.Value, "=")
+		if !ok {
+			return fmt.Errorf("want key=value")
+		}
+		elem, err := parseScalarParameter(t.Elem(), value)
+		if err != nil {
+			return err
+		}
+		if f.value.IsNil() {
+			f.value.Set(reflect.MakeMap(t))
+		}
+		f.value.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		return nil
+	default:
+		v, err := parseScalarParameter(t, e.Value)
+		if err != nil {
+			return err
+		}
+		f.value.Set(v)
+		return nil
+	}
+}
+
+func parseScalarParameter(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("want a number")
+		}
+		v.SetFloat(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if t == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return v, fmt.Errorf("want a duration such as 30s")
+			}
+			v.SetInt(int64(d))
+			break
+		}
+		x, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("want an integer")
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("want a non-negative integer")
+		}
+		v.SetUint(x)
+	}
+	return v, nil
+}
+
+func parameterTypeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "<duration>"
+	case t.Kind() == reflect.String:
+		return "<string>"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "<number>"
+	default:
+		return "<int>"
+	}
+}
+
+// syntax returns the syntax of the parameter such as "name=<int>".
+func (f *parameterField) syntax() string {
+	t := f.value.Type()
+	switch t.Kind() {
+	case reflect.Bool:
+		return f.name + "[=<bool>]"
+	case reflect.Slice:
+		return f.name + "=" + parameterTypeName(t.Elem()) + " ..."
+	case reflect.Map:
+		return f.name + "=<key>=" + parameterTypeName(t.Elem()) + " ..."
+	default:
+		return f.name + "=" + parameterTypeName(t)
+	}
+}
+
+// defaultValue returns the current value of the field, or an empty string if it is the zero value.
+func (f *parameterField) defaultValue() string {
+	if f.value.IsZero() || (f.value.Kind() == reflect.Slice || f.value.Kind() == reflect.Map) && f.value.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprint(f.value.Interface())
+}
+
+// libraryParameters are the parameters interpreted by this library rather than handlers.
+var libraryParameters = []struct {
+	key    string
+	syntax string
+	usage  string
+}{
+	{key: "annotate_code", syntax: "annotate_code[=<bool>]", usage: "write the annotations of generated files into .meta files"},
+	{key: "dump_request", syntax: "dump_request=<path>", usage: "write the request into the file for replaying it"},
+	{key: "full_stack_trace", syntax: "full_stack_trace[=<bool>]", usage: "report the full stack trace of a panic"},
+	{key: "timeout", syntax: "timeout=<duration>", usage: "cancel the code generation after the duration"},
+}
+
+// isLibraryParameter reports whether the key is reserved by this library.
+func isLibraryParameter(key string) bool {
+	for _, p := range libraryParameters {
+		if p.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// libraryParameterUsage returns a --help style documentation of the library parameters in the same format as ParameterUsage.
+func libraryParameterUsage() (string, error) {
+	b := strings.Builder{}
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, p := range libraryParameters {
+		fmt.Fprintln(w, "  "+p.syntax+"\t"+p.usage)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// libraryFlag reports whether the boolean library parameter with the given key is set in the parameter.
+func libraryFlag(parameter, key string) (bool, error) {
+	for _, e := range splitParameter(parameter) {
+		if e.Key == key {
+			if !e.HasValue {
+				return true, nil
+			}
+			b, err := strconv.ParseBool(e.Value)
+			if err != nil {
+				return false, fmt.Errorf("invalid parameter: invalid value %q for parameter %q: want true or false", e.Value, e.Key)
+			}
+			return b, nil
+		}
+	}
+	return false, nil
+}
+
+// libraryString returns the value of the library parameter with the given key in the parameter, or an empty string if it is not set.
+func libraryString(parameter, key string) string {
+	for _, e := range splitParameter(parameter) {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+// parseParameters parses the parameter except the library parameters into v, and reports the usage if "help" is given but not accepted by v.
+// An error is returned if v declares a parameter reserved by this library.
+func parseParameters(parameter string, v any) error {
+	fields, err := parameterFields(v)
+	if err != nil {
+		return err
+	}
+	for _, p := range libraryParameters {
+		if fields[p.key] != nil {
+			return fmt.Errorf("parameter %q of %T is reserved by the library", p.key, v)
+		}
+	}
+
+	entries := []string{}
+	for _, e := range splitParameter(parameter) {
+		if !isLibraryParameter(e.Key) {
+			entries = append(entries, e.String())
+		}
+	}
+	parameter = strings.Join(entries, ",")
+	err = ParseParameter(parameter, v)
+	if err == nil {
+		return nil
+	}
+	for _, e := range splitParameter(parameter) {
+		if e.Key != "help" || fields["help"] != nil {
+			continue
+		}
+		usage, usageErr := ParameterUsage(v)
+		if usageErr != nil {
+			return usageErr
+		}
+		libraryUsage, usageErr := libraryParameterUsage()
+		if usageErr != nil {
+			return usageErr
+		}
+		return fmt.Errorf("available parameters:\n%s\nreserved parameters:\n%s", usage, libraryUsage)
+	}
+	return fmt.Errorf("invalid parameter: %w", err)
+}
+
+// libraryDuration returns the value of the duration library parameter with the given key, or zero if it is not set.
+func libraryDuration(parameter, key string) (time.Duration, error) {
+	for _, e := range splitParameter(parameter) {
+		if e.Key == key {
+			d, err := time.ParseDuration(e.Value)
+			if err != nil {
+				return 0, fmt.Errorf("invalid parameter: invalid value %q for parameter %q: want a duration such as 30s", e.Value, e.Key)
+			}
+			return d, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,165 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+	"time"
+)
+
+type testParameters struct {
+	Verbose  bool              `param:"verbose" usage:"print verbose logs"`
+	Indent   int               `param:"indent" usage:"number of spaces for indentation"`
+	Prefix   string            `param:"prefix" usage:"prefix of generated names"`
+	Deadline time.Duration     `param:"deadline" usage:"deadline of generation"`
+	Exclude  []string          `param:"exclude" usage:"files to exclude"`
+	Packages map[string]string `param:"M" usage:"package of a file"`
+	Ignored  string
+}
+
+func TestParseParameter(t *testing.T) {
+	tests := []struct {
+		name      string
+		parameter string
+		want      testParameters
+		wantErr   []string
+	}{
+		{
+			name:      "empty",
+			parameter: "",
+			want:      testParameters{Indent: 2},
+		},
+		{
+			name:      "all",
+			parameter: "verbose,indent=4,prefix=Foo,deadline=1m,exclude=a.proto,exclude=b.proto,M=foo.proto=example.com/foo,M=bar.proto=example.com/bar",
+			want: testParameters{
+				Verbose:  true,
+				Indent:   4,
+				Prefix:   "Foo",
+				Deadline: time.Minute,
+				Exclude:  []string{"a.proto", "b.proto"},
+				Packages: map[string]string{"foo.proto": "example.com/foo", "bar.proto": "example.com/bar"},
+			},
+		},
+		{
+			name:      "bool with value",
+			parameter: "verbose=false",
+			want:      testParameters{Indent: 2},
+		},
+		{
+			name:      "errors",
+			parameter: "unknown,Ignored=x,indent=x,verbose=x,M=foo.proto,deadline=1",
+			want:      testParameters{Indent: 2},
+			wantErr: []string{
+				`unknown parameter "unknown"`,
+				`unknown parameter "Ignored"`,
+				`invalid value "x" for parameter "indent": want an integer`,
+				`invalid value "x" for parameter "verbose": want true or false`,
+				`invalid value "foo.proto" for parameter "M": want key=value`,
+				`invalid value "1" for parameter "deadline": want a duration such as 30s`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testParameters{Indent: 2}
+			err := protocplugin.ParseParameter(tt.parameter, &got)
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err)
+				for _, want := range tt.wantErr {
+					assert.ErrorContains(t, err, want)
+				}
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseParameter_InvalidStruct(t *testing.T) {
+	assert.Error(t, protocplugin.ParseParameter("", testParameters{}))
+	assert.Error(t, protocplugin.ParseParameter("", &struct {
+		Any any `param:"any"`
+	}{}))
+}
+
+func TestParameterUsage(t *testing.T) {
+	usage, err := protocplugin.ParameterUsage(&testParameters{Indent: 2})
+	require.NoError(t, err)
+	assert.Equal(t, ""+
+		"  verbose[=<bool>]      print verbose logs\n"+
+		"  indent=<int>          number of spaces for indentation (default: 2)\n"+
+		"  prefix=<string>       prefix of generated names\n"+
+		"  deadline=<duration>   deadline of generation\n"+
+		"  exclude=<string> ...  files to exclude\n"+
+		"  M=<key>=<string> ...  package of a file\n",
+		usage)
+}
+
+func TestRun_WithParameters(t *testing.T) {
+	tests := []struct {
+		name      string
+		parameter string
+		want      testParameters
+		wantError string
+	}{
+		{
+			name:      "valid",
+			parameter: "verbose,prefix=Foo",
+			want:      testParameters{Verbose: true, Prefix: "Foo"},
+		},
+		{
+			name:      "invalid",
+			parameter: "verbose,unknown",
+			wantError: "invalid parameter: unknown parameter \"unknown\"",
+		},
+		{
+			name:      "help",
+			parameter: "help",
+			wantError: "available parameters:\n" +
+				"  verbose[=<bool>]      print verbose logs\n" +
+				"  indent=<int>          number of spaces for indentation\n" +
+				"  prefix=<string>       prefix of generated names\n" +
+				"  deadline=<duration>   deadline of generation\n" +
+				"  exclude=<string> ...  files to exclude\n" +
+				"  M=<key>=<string> ...  package of a file\n" +
+				"\n" +
+				"reserved parameters:\n" +
+				"  annotate_code[=<bool>]     write the annotations of generated files into .meta files\n" +
+				"  dump_request=<path>        write the request into the file for replaying it\n" +
+				"  full_stack_trace[=<bool>]  report the full stack trace of a panic\n" +
+				"  timeout=<duration>         cancel the code generation after the duration\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParameters{}
+			called := false
+			handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+				called = true
+				assert.Equal(t, tt.want, params)
+				return nil, nil
+			}
+			resp := runRequest(t, newRequest(t, tt.parameter, testFileDep), handle, protocplugin.WithParameters(&params))
+			assert.Equal(t, tt.wantError, resp.GetError())
+			assert.Equal(t, tt.wantError == "", called)
+		})
+	}
+}
+
+type reservedParameters struct {
+	Timeout time.Duration `param:"timeout"`
+}
+
+func TestRun_WithParameters_Reserved(t *testing.T) {
+	params := reservedParameters{}
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		t.Fatal("handler must not be called")
+		return nil, nil
+	}
+	resp := runRequest(t, newRequest(t, "timeout=1m", testFileDep), handle, protocplugin.WithParameters(&params))
+	assert.Equal(t, `parameter "timeout" of *protocplugin_test.reservedParameters is reserved by the library`, resp.GetError())
+}
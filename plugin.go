@@ -1,6 +1,7 @@
 package protocplugin
 
 import (
+	"context"
 	"fmt"
 	"google.golang.org/genproto/googleapis/api/annotations"
 	"google.golang.org/protobuf/compiler/protogen"
@@ -8,20 +9,73 @@ import (
 	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/pluginpb"
 	"io"
+	"os"
+	"strings"
 )
 
 // GeneratedFile represents a file generated by the plugin.
 type GeneratedFile struct {
-	Name    string // Name is the name of the generated file.
-	Content string // Content is the content of the generated file.
+	Name           string        // Name is the name of the generated file.
+	Content        string        // Content is the content of the generated file.
+	InsertionPoint string        // InsertionPoint is the name of the insertion point in the file Name at which Content is inserted, or empty if the file is newly created.
+	Annotations    []*Annotation // Annotations link byte ranges of Content to the elements they are generated from.
 }
 
 // PluginHandler is a function type that handles the code generation request and returns generated files.
-type PluginHandler func(req *pluginpb.CodeGeneratorRequest, files map[string]*File) ([]*GeneratedFile, error)
+// files maps the paths of all the files in the request to their models, and registry provides lookups of their elements by full name.
+// Problems found in the request can be reported to diags, which allows a handler to report all of them at once.
+type PluginHandler func(req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error)
+
+// Option is an option of Run.
+type Option func(*config)
+
+type config struct {
+	parameters        any
+	supportedFeatures uint64
+	minimumEdition    descriptorpb.Edition
+	maximumEdition    descriptorpb.Edition
+	middlewares       []Middleware
+	warnings          io.Writer
+}
+
+// WithParameters makes Run parse the parameter of the request into the struct pointed to by v before calling the handler.
+// See ParseParameter for the supported structs.
+// Unknown keys and invalid values are reported as the error of the response without calling the handler.
+// If the parameter contains "help" which is not accepted by v, the usage of the parameters is reported as the error.
+// The keys "annotate_code", "dump_request", "full_stack_trace" and "timeout" are reserved by the library and not passed to v,
+// and v declaring any of them is reported as the error.
+func WithParameters(v any) Option {
+	return func(c *config) {
+		c.parameters = v
+	}
+}
 
 // Run executes the plugin handler with the provided input and output streams.
-func Run(in io.Reader, out io.Writer, handle PluginHandler) error {
-	opts := protogen.Options{}
+// It is equivalent to RunContext with context.Background() and the handler ignoring the context.
+// If the handler returns an error or reports errors to the diagnostics, they are returned to protoc as the error of the response.
+// Warnings reported to the diagnostics are written to the standard error.
+// The annotations of the generated files are returned to protoc as their generated code info.
+// If the parameter "annotate_code" is given, they are also written to files with the suffix ".meta" in the same way as protoc-gen-go.
+// A panic while constructing the model or in the handler is recovered and returned to protoc as the error of the response with a trimmed stack trace.
+// If the parameter "full_stack_trace" is given, the full stack trace is reported instead.
+// If the parameter "dump_request" such as "dump_request=request.binpb" or the environment variable DumpRequestEnv is given,
+// the request is written to the file so that it can be replayed by Replay.
+func Run(in io.Reader, out io.Writer, handle PluginHandler, opts ...Option) error {
+	return RunContext(context.Background(), in, out, func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error) {
+		return handle(req, files, registry, diags)
+	}, opts...)
+}
+
+// RunContext executes the context-aware plugin handler with the provided input and output streams in the same way as Run.
+// The context passed to the handler is cancelled when the process receives SIGINT or SIGTERM,
+// or the timeout given by the parameter "timeout" such as "timeout=30s" expires.
+// Then, the error of the response reports the cause with the file being generated, which the handler can record by Generating.
+func RunContext(ctx context.Context, in io.Reader, out io.Writer, handle ContextPluginHandler, opts ...Option) error {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	inBuf, err := io.ReadAll(in)
 	if err != nil {
 		return fmt.Errorf("failed to read input: %w", err)
@@ -33,27 +87,13 @@ func Run(in io.Reader, out io.Writer, handle PluginHandler) error {
 		return fmt.Errorf("failed to unmarshal request: %w", err)
 	}
 
-	p, err := opts.New(req)
-	if err != nil {
-		return fmt.Errorf("failed to create plugin instance: %w", err)
+	if err := dumpRequest(req.GetParameter(), inBuf); err != nil {
+		return err
 	}
 
-	inFiles := map[string]*File{}
-	for _, f := range p.Files {
-		inFiles[f.Desc.Path()] = constructFile(f)
-	}
-	outFiles, err := handle(req, inFiles)
-
-	resp := &pluginpb.CodeGeneratorResponse{}
+	resp, err := generate(ctx, req, handle, cfg)
 	if err != nil {
-		resp.Error = proto.String(fmt.Sprintf("%+v", err))
-	} else {
-		for _, f := range outFiles {
-			resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{
-				Name:    proto.String(f.Name),
-				Content: proto.String(f.Content),
-			})
-		}
+		return err
 	}
 
 	outBuf, err := proto.Marshal(resp)
@@ -69,49 +109,223 @@ func Run(in io.Reader, out io.Writer, handle PluginHandler) error {
 	return nil
 }
 
-func constructFile(f *protogen.File) *File {
+// generate builds the model of the request, calls the handler with it, and returns the response.
+func generate(ctx context.Context, req *pluginpb.CodeGeneratorRequest, handle ContextPluginHandler, cfg *config) (*pluginpb.CodeGeneratorResponse, error) {
+	handle = Chain(handle, cfg.middlewares...)
+	resp := &pluginpb.CodeGeneratorResponse{}
+	cfg.advertiseSupport(resp)
+	if err := cfg.checkSupport(req); err != nil {
+		resp.Error = proto.String(err.Error())
+		return resp, nil
+	}
+	if cfg.parameters != nil {
+		if err := parseParameters(req.GetParameter(), cfg.parameters); err != nil {
+			resp.Error = proto.String(err.Error())
+			return resp, nil
+		}
+	}
+
+	timeout, err := libraryDuration(req.GetParameter(), "timeout")
+	if err != nil {
+		resp.Error = proto.String(err.Error())
+		return resp, nil
+	}
+	fullStack, err := libraryFlag(req.GetParameter(), "full_stack_trace")
+	if err != nil {
+		resp.Error = proto.String(err.Error())
+		return resp, nil
+	}
+	annotateCode, err := libraryFlag(req.GetParameter(), "annotate_code")
+	if err != nil {
+		resp.Error = proto.String(err.Error())
+		return resp, nil
+	}
+
+	p, err := protogen.Options{}.New(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin instance: %w", err)
+	}
+
+	c := newConstructor()
+	inFiles := map[string]*File{}
+	if err := callSafely(fullStack, func() {
+		for _, f := range c.constructFiles(p.Files) {
+			inFiles[f.Desc.Path()] = f
+		}
+	}); err != nil {
+		resp.Error = proto.String(fmt.Sprintf("panic while constructing the model of %s: %v", c.processing, err))
+		return resp, nil
+	}
+
+	ctx, cancel := withCancellation(ctx, timeout)
+	defer cancel()
+	diags := &Diagnostics{}
+	outFiles, err := callHandler(ctx, fullStack, func(ctx context.Context) ([]*GeneratedFile, error) {
+		return handle(ctx, req, inFiles, c.registry, diags)
+	})
+	if warnings := diags.Warnings(); len(warnings) > 0 {
+		w := cfg.warnings
+		if w == nil {
+			w = os.Stderr
+		}
+		fmt.Fprintln(w, formatDiagnostics(warnings))
+	}
+
+	if errs := diags.Errors(); err != nil || len(errs) > 0 {
+		msgs := []string{}
+		if len(errs) > 0 {
+			msgs = append(msgs, formatDiagnostics(errs))
+		}
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("%+v", err))
+		}
+		resp.Error = proto.String(strings.Join(msgs, "\n"))
+	} else {
+		files, err := responseFiles(outFiles, annotateCode)
+		if err != nil {
+			resp.Error = proto.String(err.Error())
+		} else {
+			resp.File = files
+		}
+	}
+	return resp, nil
+}
+
+// responseFiles converts the generated files into the files of the response, appending the .meta files if annotateCode is true.
+func responseFiles(outFiles []*GeneratedFile, annotateCode bool) ([]*pluginpb.CodeGeneratorResponse_File, error) {
+	files := []*pluginpb.CodeGeneratorResponse_File{}
+	for _, f := range outFiles {
+		file := &pluginpb.CodeGeneratorResponse_File{
+			Name:    proto.String(f.Name),
+			Content: proto.String(f.Content),
+		}
+		if f.InsertionPoint != "" {
+			file.InsertionPoint = proto.String(f.InsertionPoint)
+		}
+		info, err := f.generatedCodeInfo()
+		if err != nil {
+			return nil, err
+		}
+		file.GeneratedCodeInfo = info
+		files = append(files, file)
+		if annotateCode && info != nil {
+			meta, err := metaFile(file)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, meta)
+		}
+	}
+	return files, nil
+}
+
+// constructor builds the model of the files in a request.
+// Each element is constructed exactly once and registered to the registry, and cross-references are resolved to those instances.
+type constructor struct {
+	registry   *Registry
+	file       *File            // file is the file being constructed.
+	locations  *sourceLocations // locations are the source locations of the file being constructed.
+	processing string           // processing is the name of the element being processed, which is reported when a panic occurs.
+}
+
+func newConstructor() *constructor {
+	return &constructor{registry: newRegistry()}
+}
+
+// constructFiles constructs the model of the given files and links the cross-references between them.
+func (c *constructor) constructFiles(files []*protogen.File) []*File {
+	out := []*File{}
+	for _, f := range files {
+		out = append(out, c.constructFile(f))
+	}
+	c.link()
+	return out
+}
+
+// link resolves the message and enum types referenced by fields and methods, the key and value fields of map fields, and the messages extended by extensions.
+func (c *constructor) link() {
+	r := c.registry
+	for _, field := range r.fields {
+		c.processing = string(field.FullName)
+		if m := field.Desc.Message(); m != nil {
+			field.Message = r.messagesByName[m.FullName()]
+			if field.IsMap && field.Message != nil {
+				field.MapKey = field.Message.Fields[field.Desc.MapKey().Index()]
+				field.MapValue = field.Message.Fields[field.Desc.MapValue().Index()]
+			}
+		}
+		if e := field.Desc.Enum(); e != nil {
+			field.Enum = r.enumsByName[e.FullName()]
+		}
+		if field.Desc.IsExtension() {
+			field.Extendee = r.messagesByName[field.Desc.ContainingMessage().FullName()]
+			if field.Extendee != nil {
+				field.Extendee.ExtendedBy = append(field.Extendee.ExtendedBy, field)
+			}
+		}
+	}
+	for _, method := range r.methods {
+		c.processing = string(method.FullName)
+		method.Input = r.messagesByName[method.Desc.Input().FullName()]
+		method.Output = r.messagesByName[method.Desc.Output().FullName()]
+	}
+}
+
+func (c *constructor) constructFile(f *protogen.File) *File {
+	c.processing = f.Desc.Path()
+	c.locations = newSourceLocations(f.Desc)
 	file := &File{
 		FullName: f.Desc.FullName(),
 		Desc:     f.Desc,
+		Location: c.locations.location(nil),
 	}
 	if o := f.Desc.Options().(*descriptorpb.FileOptions); o != nil {
 		file.Options = &FileOptions{FileOptions: o}
 	}
+	file.Features = inheritFeatures(&Features{FeatureSet: editionDefaults(fileEdition(f.Proto))}, f.Desc.Options().(*descriptorpb.FileOptions).GetFeatures())
+	c.registry.addFile(file)
+	c.file = file
 	for _, e := range f.Enums {
-		file.Enums = append(file.Enums, constructEnum(e))
+		file.Enums = append(file.Enums, c.constructEnum(file, e))
 	}
 	for _, m := range f.Messages {
-		file.Messages = append(file.Messages, constructMessage(m))
+		file.Messages = append(file.Messages, c.constructMessage(file, m))
+	}
+	for _, x := range f.Extensions {
+		file.Extensions = append(file.Extensions, c.constructField(nil, x))
 	}
 	for _, s := range f.Services {
-		file.Services = append(file.Services, constructService(file, s))
+		file.Services = append(file.Services, c.constructService(file, s))
 	}
 	return file
 }
 
-func constructService(parent *File, s *protogen.Service) *Service {
+func (c *constructor) constructService(parent *File, s *protogen.Service) *Service {
+	c.processing = string(s.Desc.FullName())
 	service := &Service{
 		FullName: s.Desc.FullName(),
 		Desc:     s.Desc,
 		Parent:   parent,
+		Location: c.locations.location(s.Location.Path),
 		Comments: s.Comments,
 	}
 	if o := s.Desc.Options().(*descriptorpb.ServiceOptions); o != nil {
 		service.Options = &ServiceOptions{ServiceOptions: o}
 	}
+	c.registry.addService(service)
 	for _, m := range s.Methods {
-		service.Methods = append(service.Methods, constructMethod(service, m))
+		service.Methods = append(service.Methods, c.constructMethod(service, m))
 	}
 	return service
 }
 
-func constructMethod(parent *Service, m *protogen.Method) *Method {
+func (c *constructor) constructMethod(parent *Service, m *protogen.Method) *Method {
+	c.processing = string(m.Desc.FullName())
 	method := &Method{
 		FullName: m.Desc.FullName(),
 		Desc:     m.Desc,
 		Parent:   parent,
-		Input:    constructMessage(m.Input),
-		Output:   constructMessage(m.Output),
+		Location: c.locations.location(m.Location.Path),
 		Comments: m.Comments,
 	}
 	if o := m.Desc.Options().(*descriptorpb.MethodOptions); o != nil {
@@ -120,82 +334,126 @@ func constructMethod(parent *Service, m *protogen.Method) *Method {
 			method.Options.Http = &HttpRule{HttpRule: httpRule}
 		}
 	}
+	c.registry.addMethod(method)
 	return method
 }
 
-func constructMessage(m *protogen.Message) *Message {
+func (c *constructor) constructMessage(parent Element, m *protogen.Message) *Message {
+	c.processing = string(m.Desc.FullName())
 	message := &Message{
-		FullName: m.Desc.FullName(),
-		Desc:     m.Desc,
-		Comments: m.Comments,
+		FullName:   m.Desc.FullName(),
+		Desc:       m.Desc,
+		Parent:     parent,
+		IsMapEntry: m.Desc.IsMapEntry(),
+		Location:   c.locations.location(m.Location.Path),
+		Comments:   m.Comments,
 	}
 	if o := m.Desc.Options().(*descriptorpb.MessageOptions); o != nil {
 		message.Options = &MessageOptions{MessageOptions: o}
 	}
+	message.Features = inheritFeatures(featuresOf(parent), m.Desc.Options().(*descriptorpb.MessageOptions).GetFeatures())
+	c.registry.addMessage(message)
 	for _, f := range m.Fields {
-		message.Fields = append(message.Fields, constructField(message, f))
+		message.Fields = append(message.Fields, c.constructField(message, f))
 	}
 	for _, m := range m.Messages {
-		message.Messages = append(message.Messages, constructMessage(m))
+		nested := c.constructMessage(message, m)
+		if nested.IsMapEntry {
+			message.MapEntries = append(message.MapEntries, nested)
+		} else {
+			message.Messages = append(message.Messages, nested)
+		}
 	}
 	for _, e := range m.Enums {
-		message.Enums = append(message.Enums, constructEnum(e))
+		message.Enums = append(message.Enums, c.constructEnum(message, e))
 	}
 	for _, o := range m.Oneofs {
-		message.Oneofs = append(message.Oneofs, constructOneof(message, o))
+		oneof := c.constructOneof(message, o)
+		if oneof.IsSynthetic {
+			message.SyntheticOneofs = append(message.SyntheticOneofs, oneof)
+		} else {
+			message.Oneofs = append(message.Oneofs, oneof)
+		}
+	}
+	for _, x := range m.Extensions {
+		message.Extensions = append(message.Extensions, c.constructField(message, x))
 	}
 	return message
 }
 
-func constructField(parent *Message, f *protogen.Field) *Field {
+func (c *constructor) constructField(parent *Message, f *protogen.Field) *Field {
+	c.processing = string(f.Desc.FullName())
 	field := &Field{
 		FullName: f.Desc.FullName(),
 		Desc:     f.Desc,
 		Parent:   parent,
+		IsMap:    f.Desc.IsMap(),
+		Location: c.locations.location(f.Location.Path),
 		Comments: f.Comments,
+		file:     c.file,
 	}
 	if o := f.Desc.Options().(*descriptorpb.FieldOptions); o != nil {
 		field.Options = &FieldOptions{FieldOptions: o}
 	}
+	if parent != nil {
+		field.Features = fieldFeatures(parent.Features, f.Desc)
+	} else {
+		field.Features = fieldFeatures(c.file.Features, f.Desc)
+	}
+	c.registry.addField(field)
 	return field
 }
 
-func constructOneof(parent *Message, o *protogen.Oneof) *Oneof {
+func (c *constructor) constructOneof(parent *Message, o *protogen.Oneof) *Oneof {
+	c.processing = string(o.Desc.FullName())
 	oneof := &Oneof{
-		FullName: o.Desc.FullName(),
-		Desc:     o.Desc,
-		Parent:   parent,
-		Comments: protogen.CommentSet{},
+		FullName:    o.Desc.FullName(),
+		Desc:        o.Desc,
+		Parent:      parent,
+		IsSynthetic: o.Desc.IsSynthetic(),
+		Location:    c.locations.location(o.Location.Path),
+		Comments:    o.Comments,
 	}
 	if o := o.Desc.Options().(*descriptorpb.OneofOptions); o != nil {
 		oneof.Options = &OneofOptions{OneofOptions: o}
 	}
+	oneof.Features = inheritFeatures(parent.Features, o.Desc.Options().(*descriptorpb.OneofOptions).GetFeatures())
 	for _, f := range o.Fields {
-		oneof.Fields = append(oneof.Fields, constructField(oneof.Parent, f))
+		// The fields of a oneof are the same instances as the ones in the parent message.
+		field := parent.Fields[f.Desc.Index()]
+		field.Oneof = oneof
+		oneof.Fields = append(oneof.Fields, field)
 	}
 	return oneof
 }
 
-func constructEnum(e *protogen.Enum) *Enum {
+func (c *constructor) constructEnum(parent Element, e *protogen.Enum) *Enum {
+	c.processing = string(e.Desc.FullName())
 	enum := &Enum{
 		FullName: e.Desc.FullName(),
 		Desc:     e.Desc,
+		Parent:   parent,
+		Location: c.locations.location(e.Location.Path),
 		Comments: e.Comments,
 	}
 	if o := e.Desc.Options().(*descriptorpb.EnumOptions); o != nil {
 		enum.Options = &EnumOptions{EnumOptions: o}
 	}
+	enum.Features = inheritFeatures(featuresOf(parent), e.Desc.Options().(*descriptorpb.EnumOptions).GetFeatures())
+	c.registry.addEnum(enum)
 	for _, v := range e.Values {
-		enum.Values = append(enum.Values, constructEnumValue(enum, v))
+		enum.Values = append(enum.Values, c.constructEnumValue(enum, v))
 	}
 	return enum
 }
 
-func constructEnumValue(parent *Enum, v *protogen.EnumValue) *EnumValue {
+func (c *constructor) constructEnumValue(parent *Enum, v *protogen.EnumValue) *EnumValue {
+	c.processing = string(v.Desc.FullName())
 	enumValue := &EnumValue{
 		FullName: v.Desc.FullName(),
 		Desc:     v.Desc,
 		Parent:   parent,
+		Location: c.locations.location(v.Location.Path),
 		Comments: v.Comments,
 	}
 	if o := v.Desc.Options().(*descriptorpb.EnumValueOptions); o != nil {
@@ -1,16 +1,27 @@
 package protocplugin_test
 
 import (
+	"bytes"
 	"fmt"
 	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/pluginpb"
 	"os"
+	"testing"
 )
 
 func ExampleRun() {
 	handle := func(
 		req *pluginpb.CodeGeneratorRequest,
 		files map[string]*protocplugin.File,
+		registry *protocplugin.Registry,
+		diags *protocplugin.Diagnostics,
 	) ([]*protocplugin.GeneratedFile, error) {
 		out := []*protocplugin.GeneratedFile{}
 		for _, f := range req.FileToGenerate {
@@ -27,3 +38,240 @@ func ExampleRun() {
 		panic(fmt.Sprintf("%+v", err))
 	}
 }
+
+// runPlugin runs the handler supporting proto3 optional fields with a request consisting of the given files written in the protobuf text format.
+// All the files are to be generated.
+func runPlugin(t *testing.T, handle protocplugin.PluginHandler, files ...string) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	return runRequest(t, newRequest(t, "", files...), handle,
+		protocplugin.WithSupportedFeatures(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL))
+}
+
+// newRequest returns a request with the parameter and the files written in the protobuf text format.
+// All the files are to be generated.
+func newRequest(t *testing.T, parameter string, files ...string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	req := &pluginpb.CodeGeneratorRequest{}
+	if parameter != "" {
+		req.Parameter = proto.String(parameter)
+	}
+	for _, f := range files {
+		fd := &descriptorpb.FileDescriptorProto{}
+		require.NoError(t, prototext.Unmarshal([]byte(f), fd))
+		req.ProtoFile = append(req.ProtoFile, fd)
+		req.FileToGenerate = append(req.FileToGenerate, fd.GetName())
+	}
+	return req
+}
+
+// runRequest runs the handler with the request through Run.
+func runRequest(t *testing.T, req *pluginpb.CodeGeneratorRequest, handle protocplugin.PluginHandler, opts ...protocplugin.Option) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	in, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	require.NoError(t, protocplugin.Run(bytes.NewReader(in), &out, handle, opts...))
+
+	resp := &pluginpb.CodeGeneratorResponse{}
+	require.NoError(t, proto.Unmarshal(out.Bytes(), resp))
+	return resp
+}
+
+const testFileDep = `
+name: "dep.proto"
+package: "dep"
+syntax: "proto3"
+options: { go_package: "example.com/dep" }
+message_type: { name: "Shared" }
+enum_type: { name: "Kind" value: { name: "KIND_UNSPECIFIED" number: 0 } }
+`
+
+const testFileMain = `
+name: "main.proto"
+package: "main"
+dependency: "dep.proto"
+syntax: "proto3"
+options: { go_package: "example.com/main" }
+message_type: {
+  name: "Request"
+  field: { name: "shared" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".dep.Shared" json_name: "shared" }
+  field: { name: "kind" number: 2 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".dep.Kind" json_name: "kind" }
+  field: { name: "inner" number: 3 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".main.Request.Inner" json_name: "inner" }
+  field: { name: "id" number: 4 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "id" oneof_index: 0 }
+  nested_type: { name: "Inner" }
+  oneof_decl: { name: "key" }
+}
+message_type: { name: "Response" }
+service: {
+  name: "Service"
+  method: { name: "Call" input_type: ".main.Request" output_type: ".main.Response" }
+}
+`
+
+func TestRun_CrossLinks(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		dep, main := files["dep.proto"], files["main.proto"]
+		request, response := main.Messages[0], main.Messages[1]
+
+		assert.Same(t, dep.Messages[0], request.Fields[0].Message)
+		assert.Nil(t, request.Fields[0].Enum)
+		assert.Same(t, dep.Enums[0], request.Fields[1].Enum)
+		assert.Nil(t, request.Fields[1].Message)
+		assert.Same(t, request.Messages[0], request.Fields[2].Message)
+		assert.Nil(t, request.Fields[3].Message)
+		assert.Nil(t, request.Fields[3].Enum)
+		assert.Same(t, request.Fields[3], request.Oneofs[0].Fields[0])
+
+		method := main.Services[0].Methods[0]
+		assert.Same(t, request, method.Input)
+		assert.Same(t, response, method.Output)
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileDep, testFileMain)
+	assert.Empty(t, resp.GetError())
+}
+
+const testFileExt = `
+name: "ext.proto"
+package: "ext"
+options: { go_package: "example.com/ext" }
+message_type: {
+  name: "Base"
+  extension_range: { start: 100 end: 200 }
+  extension: { name: "nested_ext" number: 101 label: LABEL_OPTIONAL type: TYPE_STRING extendee: ".ext.Base" json_name: "nestedExt" }
+}
+extension: { name: "top_ext" number: 100 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".ext.Base" extendee: ".ext.Base" json_name: "topExt" }
+`
+
+func TestRun_Extensions(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		file := files["ext.proto"]
+		base := file.Messages[0]
+
+		require.Len(t, file.Extensions, 1)
+		topExt := file.Extensions[0]
+		assert.Equal(t, protoreflect.FullName("ext.top_ext"), topExt.FullName)
+		assert.Nil(t, topExt.Parent)
+		assert.Same(t, base, topExt.Extendee)
+		assert.Same(t, base, topExt.Message)
+
+		require.Len(t, base.Extensions, 1)
+		nestedExt := base.Extensions[0]
+		assert.Equal(t, protoreflect.FullName("ext.Base.nested_ext"), nestedExt.FullName)
+		assert.Same(t, base, nestedExt.Parent)
+		assert.Same(t, base, nestedExt.Extendee)
+
+		assert.Equal(t, []*protocplugin.Field{nestedExt, topExt}, base.ExtendedBy)
+
+		f, err := registry.Field("ext.top_ext")
+		require.NoError(t, err)
+		assert.Same(t, topExt, f)
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileExt)
+	assert.Empty(t, resp.GetError())
+}
+
+const testFileOneof = `
+name: "oneof.proto"
+package: "oneof"
+syntax: "proto3"
+options: { go_package: "example.com/oneof" }
+message_type: {
+  name: "Message"
+  field: { name: "a" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "a" oneof_index: 0 }
+  field: { name: "b" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "b" oneof_index: 0 }
+  field: { name: "opt" number: 3 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "opt" oneof_index: 1 proto3_optional: true }
+  oneof_decl: { name: "choice" }
+  oneof_decl: { name: "_opt" }
+}
+source_code_info: {
+  location: { path: [4, 0, 8, 0] span: [5, 2, 8, 3] leading_comments: " choice is a oneof.\n" }
+}
+`
+
+func TestRun_Oneofs(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		message := files["oneof.proto"].Messages[0]
+
+		require.Len(t, message.Oneofs, 1)
+		choice := message.Oneofs[0]
+		assert.Equal(t, protoreflect.FullName("oneof.Message.choice"), choice.FullName)
+		assert.False(t, choice.IsSynthetic)
+		assert.Equal(t, protogen.Comments(" choice is a oneof.\n"), choice.Comments.Leading)
+		assert.Equal(t, []*protocplugin.Field{message.Fields[0], message.Fields[1]}, choice.Fields)
+		assert.Same(t, choice, message.Fields[0].Oneof)
+
+		require.Len(t, message.SyntheticOneofs, 1)
+		opt := message.SyntheticOneofs[0]
+		assert.Equal(t, protoreflect.FullName("oneof.Message._opt"), opt.FullName)
+		assert.True(t, opt.IsSynthetic)
+		assert.Same(t, opt, message.Fields[2].Oneof)
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileOneof)
+	assert.Empty(t, resp.GetError())
+}
+
+const testFileMap = `
+name: "map.proto"
+package: "maps"
+syntax: "proto3"
+options: { go_package: "example.com/maps" }
+message_type: {
+  name: "Message"
+  field: { name: "kinds" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".maps.Message.KindsEntry" json_name: "kinds" }
+  field: { name: "values" number: 2 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".maps.Message.ValuesEntry" json_name: "values" }
+  nested_type: {
+    name: "KindsEntry"
+    field: { name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "key" }
+    field: { name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".maps.Kind" json_name: "value" }
+    options: { map_entry: true }
+  }
+  nested_type: {
+    name: "ValuesEntry"
+    field: { name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "key" }
+    field: { name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".maps.Value" json_name: "value" }
+    options: { map_entry: true }
+  }
+  nested_type: { name: "Nested" }
+}
+message_type: { name: "Value" }
+enum_type: { name: "Kind" value: { name: "KIND_UNSPECIFIED" number: 0 } }
+`
+
+func TestRun_Maps(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		file := files["map.proto"]
+		message, value, kind := file.Messages[0], file.Messages[1], file.Enums[0]
+
+		require.Len(t, message.Messages, 1)
+		assert.Equal(t, protoreflect.FullName("maps.Message.Nested"), message.Messages[0].FullName)
+		require.Len(t, message.MapEntries, 2)
+		kindsEntry, valuesEntry := message.MapEntries[0], message.MapEntries[1]
+		assert.True(t, kindsEntry.IsMapEntry)
+		assert.False(t, message.IsMapEntry)
+
+		kinds := message.Fields[0]
+		assert.True(t, kinds.IsMap)
+		assert.Same(t, kindsEntry, kinds.Message)
+		assert.Same(t, kindsEntry.Fields[0], kinds.MapKey)
+		assert.Same(t, kindsEntry.Fields[1], kinds.MapValue)
+		assert.Equal(t, protoreflect.StringKind, kinds.MapKey.Desc.Kind())
+		assert.Same(t, kind, kinds.MapValue.Enum)
+
+		values := message.Fields[1]
+		assert.True(t, values.IsMap)
+		assert.Same(t, valuesEntry, values.Message)
+		assert.Equal(t, protoreflect.Int64Kind, values.MapKey.Desc.Kind())
+		assert.Same(t, value, values.MapValue.Message)
+
+		m, err := registry.Message("maps.Message.KindsEntry")
+		require.NoError(t, err)
+		assert.Same(t, kindsEntry, m)
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileMap)
+	assert.Empty(t, resp.GetError())
+}
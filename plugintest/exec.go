@@ -0,0 +1,68 @@
+package plugintest
+
+import (
+	"bytes"
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os/exec"
+	"testing"
+)
+
+// ExecResult is the result of running a plugin binary by Exec.
+type ExecResult struct {
+	Response *pluginpb.CodeGeneratorResponse // Response is the response written to the standard output, or nil if the plugin exits with a non-zero status or its output cannot be decoded.
+	Stdout   []byte                          // Stdout is the raw standard output of the plugin.
+	Stderr   string                          // Stderr is the standard error of the plugin.
+	ExitCode int                             // ExitCode is the exit status of the plugin.
+	Files    map[string]string               // Files maps the names of the generated files to their contents after the insertion points are applied, or is nil if the plugin failed.
+}
+
+// Exec runs the plugin binary at the path with the arguments in the same way as protoc, and returns the result.
+// The request is written to the standard input of the plugin, and the response is read from its standard output.
+// The plugin fails if it exits with a non-zero status, its output cannot be decoded, or the response has an error,
+// and then Files of the result is nil. Otherwise, the files inserted at insertion points are applied to the files created before.
+// It fails the test if the plugin cannot be started, or a file is inserted at an insertion point which is not found.
+func Exec(t testing.TB, req *pluginpb.CodeGeneratorRequest, path string, args ...string) *ExecResult {
+	t.Helper()
+	in, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	result := &ExecResult{}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("failed to run plugin: %v", err)
+		}
+		result.ExitCode = exitErr.ExitCode()
+	}
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.String()
+
+	if result.ExitCode != 0 {
+		return result
+	}
+	resp := &pluginpb.CodeGeneratorResponse{}
+	if err := proto.Unmarshal(result.Stdout, resp); err != nil {
+		return result
+	}
+	result.Response = resp
+	if resp.Error != nil {
+		return result
+	}
+
+	files, err := applyFiles(resp.GetFile())
+	if err != nil {
+		t.Errorf("failed to apply generated files: %v", err)
+		return result
+	}
+	result.Files = files
+	return result
+}
@@ -0,0 +1,95 @@
+package plugintest_test
+
+import (
+	"fmt"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/Jumpaku/protoc-plugin-lib/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"testing"
+)
+
+// pluginModeEnv makes the test binary behave as a plugin in the mode given by its value.
+const pluginModeEnv = "PLUGINTEST_PLUGIN_MODE"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(pluginModeEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "generate":
+		if err := protocplugin.Run(os.Stdin, os.Stdout, generateMessages); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "error":
+		if err := protocplugin.Run(os.Stdin, os.Stdout, func(*pluginpb.CodeGeneratorRequest, map[string]*protocplugin.File, *protocplugin.Registry, *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+			return nil, fmt.Errorf("something went wrong")
+		}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "crash":
+		fmt.Fprint(os.Stderr, "crashed")
+		os.Exit(3)
+	case "garbage":
+		fmt.Fprint(os.Stdout, "garbage")
+	case "empty":
+	}
+	os.Exit(0)
+}
+
+func TestExec(t *testing.T) {
+	req := plugintest.NewRequest(t, []*descriptorpb.FileDescriptorProto{plugintest.ParseFile(t, testFile)}, []string{"example.proto"}, "a=b")
+	tests := []struct {
+		mode         string
+		wantResponse bool
+		wantError    string
+		wantStderr   string
+		wantExitCode int
+		wantFiles    map[string]string
+	}{
+		{
+			mode:         "generate",
+			wantResponse: true,
+			wantFiles:    map[string]string{"messages.txt": "example.Example\nparameter=a=b\n// @@protoc_insertion_point(end)\n"},
+		},
+		{
+			mode:         "error",
+			wantResponse: true,
+			wantError:    "something went wrong",
+		},
+		{
+			mode:         "crash",
+			wantStderr:   "crashed",
+			wantExitCode: 3,
+		},
+		{
+			mode: "garbage",
+		},
+		{
+			mode:         "empty",
+			wantResponse: true,
+			wantFiles:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv(pluginModeEnv, tt.mode)
+
+			result := plugintest.Exec(t, req, os.Args[0])
+
+			assert.Equal(t, tt.wantExitCode, result.ExitCode)
+			assert.Equal(t, tt.wantStderr, result.Stderr)
+			assert.Equal(t, tt.wantFiles, result.Files)
+			if !tt.wantResponse {
+				assert.Nil(t, result.Response)
+				return
+			}
+			require.NotNil(t, result.Response)
+			assert.Equal(t, tt.wantError, result.Response.GetError())
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package plugintest
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/pmezard/go-difflib/difflib"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// UpdateEnv is the environment variable which makes AssertGolden rewrite the golden files instead of comparing with them if it is set to true, e.g. "PLUGINTEST_UPDATE=true go test ./...".
+const UpdateEnv = "PLUGINTEST_UPDATE"
+
+// updating reports whether the golden files are to be rewritten, which is requested by UpdateEnv or the flag "-update" if the test package defines it.
+// The flag is not defined by this package so as not to conflict with the flag of the test package.
+func updating() bool {
+	if update, err := strconv.ParseBool(os.Getenv(UpdateEnv)); err == nil && update {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		update, err := strconv.ParseBool(f.Value.String())
+		return err == nil && update
+	}
+	return false
+}
+
+// LoadDescriptorSet returns a request with the parameter to generate the files in fileToGenerate,
+// which is built from the FileDescriptorSet in the file at the path, e.g. produced by "protoc --include_imports -o" or "buf build -o".
+// It fails the test if the file cannot be loaded.
+func LoadDescriptorSet(t testing.TB, path, parameter string, fileToGenerate ...string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read descriptor set: %v", err)
+	}
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, fds); err != nil {
+		t.Fatalf("failed to unmarshal descriptor set: %v", err)
+	}
+	req, err := protocplugin.NewRequest(fds.GetFile(), fileToGenerate, parameter)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	return req
+}
+
+// LoadRequest returns the request in the file at the path, e.g. captured by the parameter "dump_request" of protocplugin.Run.
+// It fails the test if the file cannot be loaded.
+func LoadRequest(t testing.TB, path string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read request: %v", err)
+	}
+	req := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(b, req); err != nil {
+		t.Fatalf("failed to unmarshal request: %v", err)
+	}
+	return req
+}
+
+// AssertGolden asserts that the files generated in the response are the same as the files in the golden directory, and reports whether the assertion succeeded.
+// The files inserted at insertion points are applied to the files created in the response before the comparison.
+// Each mismatch is reported as a unified diff, and missing or extra files in the directory are also reported.
+// If the environment variable UpdateEnv is set to true or the flag "-update" defined by the test package is given, the golden directory is rewritten with the generated files instead.
+// Such a flag can be defined as follows:
+//
+//	var _ = flag.Bool("update", false, "update golden files")
+func AssertGolden(t testing.TB, resp *pluginpb.CodeGeneratorResponse, dir string) bool {
+	t.Helper()
+	if !AssertNoError(t, resp) {
+		return false
+	}
+	got, err := applyFiles(resp.GetFile())
+	if err != nil {
+		t.Errorf("failed to apply generated files: %v", err)
+		return false
+	}
+	want, err := readGolden(dir)
+	if err != nil {
+		t.Errorf("failed to read golden files: %v", err)
+		return false
+	}
+
+	if updating() {
+		if err := writeGolden(dir, want, got); err != nil {
+			t.Errorf("failed to update golden files: %v", err)
+			return false
+		}
+		return true
+	}
+
+	ok := true
+	for _, name := range sortedKeys(got) {
+		wantContent, exists := want[name]
+		if !exists {
+			t.Errorf("golden file %q is missing in %s, run the test with %s=true to create it", name, dir, UpdateEnv)
+			ok = false
+			continue
+		}
+		if wantContent != got[name] {
+			diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        splitLines(wantContent),
+				B:        splitLines(got[name]),
+				FromFile: "golden/" + name,
+				ToFile:   "generated/" + name,
+				Context:  3,
+			})
+			t.Errorf("generated file %q differs from the golden file:\n%s", name, diff)
+			ok = false
+		}
+	}
+	for _, name := range sortedKeys(want) {
+		if _, exists := got[name]; !exists {
+			t.Errorf("golden file %q is not generated, run the test with %s=true to remove it", name, UpdateEnv)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// applyFiles returns the contents of the files in the response by their names, where the files with insertion points are inserted into the files created before.
+func applyFiles(files []*pluginpb.CodeGeneratorResponse_File) (map[string]string, error) {
+	contents := map[string]string{}
+	for _, f := range files {
+		if f.GetInsertionPoint() == "" {
+			contents[f.GetName()] = f.GetContent()
+			continue
+		}
+		content, ok := contents[f.GetName()]
+		if !ok {
+			return nil, fmt.Errorf("%s: file to insert into at %q is not generated", f.GetName(), f.GetInsertionPoint())
+		}
+		content, err := protocplugin.ApplyInsertion(content, &protocplugin.GeneratedFile{Name: f.GetName(), Content: f.GetContent(), InsertionPoint: f.GetInsertionPoint()})
+		if err != nil {
+			return nil, err
+		}
+		contents[f.GetName()] = content
+	}
+	return contents, nil
+}
+
+// readGolden returns the contents of the files in the directory by their slash-separated paths relative to the directory.
+// The directory is regarded as empty if it does not exist.
+func readGolden(dir string) (map[string]string, error) {
+	contents := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return fs.SkipAll
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		contents[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	return contents, err
+}
+
+// writeGolden removes the old golden files and writes the new ones into the directory.
+func writeGolden(dir string, oldFiles, newFiles map[string]string) error {
+	for name := range oldFiles {
+		if _, ok := newFiles[name]; !ok {
+			if err := os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+				return err
+			}
+		}
+	}
+	for name, content := range newFiles {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("generated file name %q must be a relative path within the golden directory", name)
+		}
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitLines splits the content into lines, each of which ends with a newline except the last one without a trailing newline.
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,157 @@
+package plugintest_test
+
+import (
+	"bytes"
+	"flag"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/Jumpaku/protoc-plugin-lib/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssertGolden(t *testing.T) {
+	resp := plugintest.Run(t, plugintest.NewRequest(t, []*descriptorpb.FileDescriptorProto{plugintest.ParseFile(t, testFile)}, []string{"example.proto"}, "a=b"), generateMessages)
+
+	plugintest.AssertGolden(t, resp, filepath.Join("testdata", "golden"))
+}
+
+func TestAssertGolden_Mismatch(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "messages.txt"), []byte("example.Example\nparameter=\n// @@protoc_insertion_point(end)\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "extra.txt"), []byte("extra\n"), 0o644))
+	resp := &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+		{Name: proto.String("messages.txt"), Content: proto.String("example.Example\nparameter=a=b\n// @@protoc_insertion_point(end)\n")},
+		{Name: proto.String("dir/missing.txt"), Content: proto.String("missing\n")},
+	}}
+	r := &recorder{TB: t}
+
+	ok := plugintest.AssertGolden(r, resp, dir)
+
+	assert.False(t, ok)
+	assert.Equal(t, []string{
+		`golden file "dir/missing.txt" is missing in ` + dir + `, run the test with PLUGINTEST_UPDATE=true to create it`,
+		"generated file \"messages.txt\" differs from the golden file:\n" +
+			"--- golden/messages.txt\n" +
+			"+++ generated/messages.txt\n" +
+			"@@ -1,3 +1,3 @@\n" +
+			" example.Example\n" +
+			"-parameter=\n" +
+			"+parameter=a=b\n" +
+			" // @@protoc_insertion_point(end)\n",
+		`golden file "extra.txt" is not generated, run the test with PLUGINTEST_UPDATE=true to remove it`,
+	}, r.errors)
+}
+
+func TestAssertGolden_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *pluginpb.CodeGeneratorResponse
+		want string
+	}{
+		{
+			name: "response error",
+			resp: &pluginpb.CodeGeneratorResponse{Error: proto.String("something went wrong")},
+			want: "response has an error: something went wrong",
+		},
+		{
+			name: "insertion into missing file",
+			resp: &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+				{Name: proto.String("a.txt"), Content: proto.String("x\n"), InsertionPoint: proto.String("end")},
+			}},
+			want: `failed to apply generated files: a.txt: file to insert into at "end" is not generated`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+
+			ok := plugintest.AssertGolden(r, tt.resp, t.TempDir())
+
+			assert.False(t, ok)
+			assert.Equal(t, []string{tt.want}, r.errors)
+		})
+	}
+}
+
+func TestAssertGolden_Update(t *testing.T) {
+	t.Run("environment variable", func(t *testing.T) {
+		t.Setenv(plugintest.UpdateEnv, "true")
+		testAssertGoldenUpdate(t)
+	})
+	t.Run("flag", func(t *testing.T) {
+		require.NoError(t, flag.Set("update", "true"))
+		t.Cleanup(func() { require.NoError(t, flag.Set("update", "false")) })
+		testAssertGoldenUpdate(t)
+	})
+}
+
+// The flag "-update" defined by the test package is respected by AssertGolden.
+var _ = flag.Bool("update", false, "update golden files")
+
+func testAssertGoldenUpdate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "golden")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "extra.txt"), []byte("extra\n"), 0o644))
+	resp := &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+		{Name: proto.String("dir/a.txt"), Content: proto.String("a\n")},
+	}}
+
+	ok := plugintest.AssertGolden(t, resp, dir)
+
+	assert.True(t, ok)
+	b, err := os.ReadFile(filepath.Join(dir, "dir", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a\n", string(b))
+	_, err = os.Stat(filepath.Join(dir, "extra.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadDescriptorSet(t *testing.T) {
+	dep := plugintest.ParseFile(t, `
+name: "dep.proto"
+package: "dep"
+syntax: "proto3"
+message_type: { name: "Dep" }
+options: { go_package: "example.com/dep" }
+`)
+	main := plugintest.ParseFile(t, `
+name: "main.proto"
+package: "main"
+syntax: "proto3"
+dependency: "dep.proto"
+message_type: { name: "Main" field: { name: "dep" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".dep.Dep" json_name: "dep" } }
+options: { go_package: "example.com/main" }
+`)
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{main, dep}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "descriptor_set.binpb")
+	require.NoError(t, os.WriteFile(path, b, 0o644))
+
+	req := plugintest.LoadDescriptorSet(t, path, "a=b", "main.proto")
+
+	assert.Equal(t, "a=b", req.GetParameter())
+	assert.Equal(t, []string{"main.proto"}, req.GetFileToGenerate())
+	require.Len(t, req.GetProtoFile(), 2)
+	assert.Equal(t, "dep.proto", req.GetProtoFile()[0].GetName())
+	assert.Equal(t, "main.proto", req.GetProtoFile()[1].GetName())
+	resp := plugintest.Run(t, req, generateMessages)
+	plugintest.AssertFileContains(t, resp, "messages.txt", "main.Main\n")
+}
+
+func TestLoadRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "request.binpb")
+	dumped := plugintest.NewRequest(t, []*descriptorpb.FileDescriptorProto{plugintest.ParseFile(t, testFile)}, []string{"example.proto"}, "a=b,dump_request="+path)
+	in, err := proto.Marshal(dumped)
+	require.NoError(t, err)
+	require.NoError(t, protocplugin.Run(bytes.NewReader(in), &bytes.Buffer{}, generateMessages))
+
+	req := plugintest.LoadRequest(t, path)
+
+	assert.True(t, proto.Equal(dumped, req))
+}
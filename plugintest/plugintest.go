@@ -0,0 +1,160 @@
+// Package plugintest provides helpers to test plugin handlers in-process without protoc.
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ParseFile returns the FileDescriptorProto written in the protobuf text format.
+// It fails the test if the text is invalid.
+func ParseFile(t testing.TB, text string) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+	fd := &descriptorpb.FileDescriptorProto{}
+	if err := prototext.Unmarshal([]byte(text), fd); err != nil {
+		t.Fatalf("failed to parse file descriptor: %v", err)
+	}
+	return fd
+}
+
+// NewRequest returns a request with the parameter to generate the files in fileToGenerate, which is built by protocplugin.NewRequest.
+// The files are sorted so that each file follows its dependencies as protoc sends them.
+// It fails the test if a file to generate or a dependency is not in the files.
+func NewRequest(t testing.TB, files []*descriptorpb.FileDescriptorProto, fileToGenerate []string, parameter string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	req, err := protocplugin.NewRequest(files, fileToGenerate, parameter)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	return req
+}
+
+// Run runs the handler with the request through protocplugin.Run and returns the decoded response.
+// It fails the test if Run returns an error, which means the request or the response cannot be exchanged with protoc.
+func Run(t testing.TB, req *pluginpb.CodeGeneratorRequest, handle protocplugin.PluginHandler, opts ...protocplugin.Option) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	return exchange(t, req, func(in *bytes.Reader, out *bytes.Buffer) error {
+		return protocplugin.Run(in, out, handle, opts...)
+	})
+}
+
+// RunContext runs the context-aware handler with the request through protocplugin.RunContext and returns the decoded response.
+// It fails the test if RunContext returns an error.
+func RunContext(t testing.TB, ctx context.Context, req *pluginpb.CodeGeneratorRequest, handle protocplugin.ContextPluginHandler, opts ...protocplugin.Option) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	return exchange(t, req, func(in *bytes.Reader, out *bytes.Buffer) error {
+		return protocplugin.RunContext(ctx, in, out, handle, opts...)
+	})
+}
+
+// exchange marshals the request, runs the plugin with it, and unmarshals the response.
+func exchange(t testing.TB, req *pluginpb.CodeGeneratorRequest, run func(in *bytes.Reader, out *bytes.Buffer) error) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	in, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+
+	out := bytes.Buffer{}
+	if err := run(bytes.NewReader(in), &out); err != nil {
+		t.Fatalf("failed to run plugin: %v", err)
+	}
+
+	resp := &pluginpb.CodeGeneratorResponse{}
+	if err := proto.Unmarshal(out.Bytes(), resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return resp
+}
+
+// File returns the file newly created with the name in the response, or nil if it is not found.
+// Files inserted at insertion points are not returned.
+func File(resp *pluginpb.CodeGeneratorResponse, name string) *pluginpb.CodeGeneratorResponse_File {
+	for _, f := range resp.GetFile() {
+		if f.GetName() == name && f.GetInsertionPoint() == "" {
+			return f
+		}
+	}
+	return nil
+}
+
+// AssertNoError asserts that the response has no error, and reports whether the assertion succeeded.
+func AssertNoError(t testing.TB, resp *pluginpb.CodeGeneratorResponse) bool {
+	t.Helper()
+	if resp.Error != nil {
+		t.Errorf("response has an error: %s", resp.GetError())
+		return false
+	}
+	return true
+}
+
+// AssertError asserts that the error of the response contains the substring, and reports whether the assertion succeeded.
+func AssertError(t testing.TB, resp *pluginpb.CodeGeneratorResponse, substr string) bool {
+	t.Helper()
+	if resp.Error == nil {
+		t.Errorf("response has no error, want an error containing %q", substr)
+		return false
+	}
+	if !strings.Contains(resp.GetError(), substr) {
+		t.Errorf("response has the error %q, want an error containing %q", resp.GetError(), substr)
+		return false
+	}
+	return true
+}
+
+// AssertFileNames asserts that the names of the files in the response are the names in the order, and reports whether the assertion succeeded.
+// Files inserted at insertion points are named "name@insertion_point".
+func AssertFileNames(t testing.TB, resp *pluginpb.CodeGeneratorResponse, names ...string) bool {
+	t.Helper()
+	got := []string{}
+	for _, f := range resp.GetFile() {
+		name := f.GetName()
+		if f.GetInsertionPoint() != "" {
+			name += "@" + f.GetInsertionPoint()
+		}
+		got = append(got, name)
+	}
+	if !slices.Equal(got, names) {
+		t.Errorf("response has the files %q, want %q", got, names)
+		return false
+	}
+	return true
+}
+
+// AssertFileContent asserts that the file newly created with the name in the response has the content, and reports whether the assertion succeeded.
+func AssertFileContent(t testing.TB, resp *pluginpb.CodeGeneratorResponse, name, content string) bool {
+	t.Helper()
+	f := File(resp, name)
+	if f == nil {
+		t.Errorf("response has no file %q", name)
+		return false
+	}
+	if f.GetContent() != content {
+		t.Errorf("file %q has the content:\n%s\nwant:\n%s", name, f.GetContent(), content)
+		return false
+	}
+	return true
+}
+
+// AssertFileContains asserts that the file newly created with the name in the response contains the substring, and reports whether the assertion succeeded.
+func AssertFileContains(t testing.TB, resp *pluginpb.CodeGeneratorResponse, name, substr string) bool {
+	t.Helper()
+	f := File(resp, name)
+	if f == nil {
+		t.Errorf("response has no file %q", name)
+		return false
+	}
+	if !strings.Contains(f.GetContent(), substr) {
+		t.Errorf("file %q has the content:\n%s\nwant the content containing:\n%s", name, f.GetContent(), substr)
+		return false
+	}
+	return true
+}
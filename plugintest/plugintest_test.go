@@ -0,0 +1,169 @@
+package plugintest_test
+
+import (
+	"context"
+	"fmt"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/Jumpaku/protoc-plugin-lib/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+// recorder records the failures of assertions instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+const testFile = `
+name: "example.proto"
+package: "example"
+syntax: "proto3"
+message_type: { name: "Example" }
+options: { go_package: "example.com/example" }
+`
+
+// generateMessages generates a file listing the messages and inserts a line into it.
+func generateMessages(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+	content := ""
+	for _, m := range registry.Messages() {
+		content += string(m.FullName) + "\n"
+	}
+	return []*protocplugin.GeneratedFile{
+		{Name: "messages.txt", Content: content + protocplugin.InsertionPointMarker("messages.txt", "end") + "\n"},
+		{Name: "messages.txt", Content: "parameter=" + req.GetParameter() + "\n", InsertionPoint: "end"},
+	}, nil
+}
+
+func TestRun(t *testing.T) {
+	req := plugintest.NewRequest(t, []*descriptorpb.FileDescriptorProto{plugintest.ParseFile(t, testFile)}, []string{"example.proto"}, "a=b")
+
+	resp := plugintest.Run(t, req, generateMessages)
+
+	plugintest.AssertNoError(t, resp)
+	plugintest.AssertFileNames(t, resp, "messages.txt", "messages.txt@end")
+	plugintest.AssertFileContent(t, resp, "messages.txt", "example.Example\n// @@protoc_insertion_point(end)\n")
+	plugintest.AssertFileContains(t, resp, "messages.txt", "example.Example")
+	assert.Equal(t, "parameter=a=b\n", resp.File[1].GetContent())
+}
+
+func TestRunContext(t *testing.T) {
+	req := plugintest.NewRequest(t, []*descriptorpb.FileDescriptorProto{plugintest.ParseFile(t, testFile)}, []string{"example.proto"}, "")
+
+	resp := plugintest.RunContext(t, context.Background(), req, func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		return nil, ctx.Err()
+	})
+
+	plugintest.AssertNoError(t, resp)
+	plugintest.AssertFileNames(t, resp)
+}
+
+func TestAssertions(t *testing.T) {
+	req := plugintest.NewRequest(t, []*descriptorpb.FileDescriptorProto{plugintest.ParseFile(t, testFile)}, []string{"example.proto"}, "")
+	resp := plugintest.Run(t, req, generateMessages)
+	failed := plugintest.Run(t, req, func(*pluginpb.CodeGeneratorRequest, map[string]*protocplugin.File, *protocplugin.Registry, *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		return nil, fmt.Errorf("something went wrong")
+	})
+
+	tests := []struct {
+		name   string
+		assert func(t testing.TB) bool
+		want   []string
+	}{
+		{
+			name:   "no error",
+			assert: func(t testing.TB) bool { return plugintest.AssertNoError(t, resp) },
+		},
+		{
+			name:   "no error fails",
+			assert: func(t testing.TB) bool { return plugintest.AssertNoError(t, failed) },
+			want:   []string{"response has an error: something went wrong"},
+		},
+		{
+			name:   "error",
+			assert: func(t testing.TB) bool { return plugintest.AssertError(t, failed, "went wrong") },
+		},
+		{
+			name:   "error fails without error",
+			assert: func(t testing.TB) bool { return plugintest.AssertError(t, resp, "went wrong") },
+			want:   []string{`response has no error, want an error containing "went wrong"`},
+		},
+		{
+			name:   "error fails with another error",
+			assert: func(t testing.TB) bool { return plugintest.AssertError(t, failed, "not found") },
+			want:   []string{`response has the error "something went wrong", want an error containing "not found"`},
+		},
+		{
+			name:   "file names fails",
+			assert: func(t testing.TB) bool { return plugintest.AssertFileNames(t, resp, "messages.txt") },
+			want:   []string{`response has the files ["messages.txt" "messages.txt@end"], want ["messages.txt"]`},
+		},
+		{
+			name:   "file content fails without file",
+			assert: func(t testing.TB) bool { return plugintest.AssertFileContent(t, resp, "unknown.txt", "") },
+			want:   []string{`response has no file "unknown.txt"`},
+		},
+		{
+			name:   "file content fails",
+			assert: func(t testing.TB) bool { return plugintest.AssertFileContent(t, resp, "messages.txt", "x\n") },
+			want:   []string{"file \"messages.txt\" has the content:\nexample.Example\n// @@protoc_insertion_point(end)\n\nwant:\nx\n"},
+		},
+		{
+			name:   "file contains fails",
+			assert: func(t testing.TB) bool { return plugintest.AssertFileContains(t, resp, "messages.txt", "Unknown") },
+			want:   []string{"file \"messages.txt\" has the content:\nexample.Example\n// @@protoc_insertion_point(end)\n\nwant the content containing:\nUnknown"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+
+			ok := tt.assert(r)
+
+			assert.Equal(t, len(tt.want) == 0, ok)
+			assert.Equal(t, tt.want, r.errors)
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	resp := plugintest.Run(t, plugintest.NewRequest(t, []*descriptorpb.FileDescriptorProto{plugintest.ParseFile(t, testFile)}, []string{"example.proto"}, ""), generateMessages)
+
+	f := plugintest.File(resp, "messages.txt")
+	require.NotNil(t, f)
+	assert.Empty(t, f.GetInsertionPoint())
+	assert.Nil(t, plugintest.File(resp, "unknown.txt"))
+}
+
+func TestNewRequest(t *testing.T) {
+	dep := plugintest.ParseFile(t, `
+name: "dep.proto"
+package: "dep"
+syntax: "proto3"
+options: { go_package: "example.com/dep" }
+`)
+	main := plugintest.ParseFile(t, `
+name: "main.proto"
+package: "main"
+syntax: "proto3"
+dependency: "dep.proto"
+options: { go_package: "example.com/main" }
+`)
+
+	req := plugintest.NewRequest(t, []*descriptorpb.FileDescriptorProto{main, dep}, []string{"main.proto"}, "a=b")
+
+	assert.Equal(t, "a=b", req.GetParameter())
+	assert.Equal(t, []string{"main.proto"}, req.GetFileToGenerate())
+	require.Len(t, req.GetProtoFile(), 2)
+	assert.Equal(t, "dep.proto", req.GetProtoFile()[0].GetName())
+	assert.Equal(t, "main.proto", req.GetProtoFile()[1].GetName())
+}
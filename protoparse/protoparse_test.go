@@ -0,0 +1,146 @@
+package protoparse_test
+
+import (
+	"context"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/Jumpaku/protoc-plugin-lib/plugintest"
+	"github.com/Jumpaku/protoc-plugin-lib/protoparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testSources = map[string]string{
+	"dep.proto": `syntax = "proto3";
+package dep;
+option go_package = "example.com/dep";
+
+enum Kind {
+  KIND_UNSPECIFIED = 0;
+}
+`,
+	"main.proto": `syntax = "proto3";
+package main;
+option go_package = "example.com/main";
+
+import "dep.proto";
+import "google/api/annotations.proto";
+import "google/protobuf/timestamp.proto";
+
+// Request is a request.
+message Request {
+  dep.Kind kind = 1;
+  google.protobuf.Timestamp time = 2;
+  optional string id = 3;
+}
+
+service Service {
+  rpc Call(Request) returns (Request) {
+    option (google.api.http) = { get: "/v1/call" };
+  }
+}
+`,
+	"editions.proto": `edition = "2023";
+package editions;
+option go_package = "example.com/editions";
+
+message Message {
+  string name = 1 [features.field_presence = IMPLICIT];
+}
+`,
+}
+
+func TestParse(t *testing.T) {
+	files, err := protoparse.Parse(context.Background(), []string{"main.proto", "editions.proto"}, protoparse.WithSources(testSources))
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, f := range files {
+		names = append(names, f.GetName())
+	}
+	assert.Equal(t, []string{
+		"dep.proto",
+		"google/api/http.proto",
+		"google/protobuf/descriptor.proto",
+		"google/api/annotations.proto",
+		"google/protobuf/timestamp.proto",
+		"main.proto",
+		"editions.proto",
+	}, names)
+
+	req, err := protocplugin.NewRequest(files, []string{"main.proto", "editions.proto"}, "")
+	require.NoError(t, err)
+	resp := plugintest.Run(t, req, func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		request, err := registry.Message("main.Request")
+		require.NoError(t, err)
+		assert.Equal(t, " Request is a request.\n", string(request.Comments.Leading))
+		assert.Equal(t, "main.proto:10:1", request.Location.String())
+		assert.Equal(t, "dep.Kind", string(request.Fields[0].Enum.FullName))
+		assert.Equal(t, "google.protobuf.Timestamp", string(request.Fields[1].Message.FullName))
+		assert.True(t, request.Fields[2].HasPresence())
+
+		method, err := registry.Method("main.Service.Call")
+		require.NoError(t, err)
+		require.NotNil(t, method.Options.Http)
+		assert.Equal(t, "/v1/call", method.Options.Http.GetGet())
+
+		name, err := registry.Field("editions.Message.name")
+		require.NoError(t, err)
+		assert.False(t, name.HasPresence())
+		return nil, nil
+	}, protocplugin.WithSupportedFeatures(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL), protocplugin.WithSupportedEditions(descriptorpb.Edition_EDITION_PROTO2, descriptorpb.Edition_EDITION_2023))
+	plugintest.AssertNoError(t, resp)
+}
+
+func TestParse_ImportPaths(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dep.proto"), []byte(testSources["dep.proto"]), 0o644))
+
+	files, err := protoparse.Parse(context.Background(), []string{"dep.proto"}, protoparse.WithImportPaths(dir))
+	require.NoError(t, err)
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "dep.proto", files[0].GetName())
+	assert.NotEmpty(t, files[0].GetSourceCodeInfo().GetLocation())
+}
+
+func TestParse_Error(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources map[string]string
+		wantErr []string
+	}{
+		{
+			name:    "syntax error",
+			sources: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage A {\n  string a = 1\n}\n"},
+			wantErr: []string{"a.proto:4:1: syntax error: expecting ';'"},
+		},
+		{
+			name:    "link errors",
+			sources: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage A {\n  B b = 1;\n  C c = 2;\n}\n"},
+			wantErr: []string{
+				`a.proto:3:3: field A.b: unknown type B`,
+				`a.proto:4:3: field A.c: unknown type C`,
+			},
+		},
+		{
+			name:    "file not found",
+			sources: map[string]string{},
+			wantErr: []string{`could not resolve path "a.proto": file does not exist`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := protoparse.Parse(context.Background(), []string{"a.proto"}, protoparse.WithSources(tt.sources), protoparse.WithImportPaths(t.TempDir()))
+
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
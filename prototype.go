@@ -8,12 +8,15 @@ import (
 
 // File represents a protobuf file descriptor.
 type File struct {
-	FullName protoreflect.FullName       // FullName is the full name of the file.
-	Desc     protoreflect.FileDescriptor // Desc is the file descriptor.
-	Options  *FileOptions                // Options are the file options.
-	Enums    []*Enum                     // Enums are the enums defined in the file.
-	Messages []*Message                  // Messages are the messages defined in the file.
-	Services []*Service                  // Services are the services defined in the file.
+	FullName   protoreflect.FullName       // FullName is the full name of the file.
+	Desc       protoreflect.FileDescriptor // Desc is the file descriptor.
+	Options    *FileOptions                // Options are the file options.
+	Features   *Features                   // Features are the resolved editions features of the file.
+	Enums      []*Enum                     // Enums are the enums defined in the file.
+	Messages   []*Message                  // Messages are the messages defined in the file.
+	Extensions []*Field                    // Extensions are the extension fields declared at the top level of the file.
+	Services   []*Service                  // Services are the services defined in the file.
+	Location   Location                    // Location is the location of the file.
 }
 
 // FileOptions represents the options for a protobuf file.
@@ -26,7 +29,10 @@ type Enum struct {
 	FullName protoreflect.FullName       // FullName is the full name of the enum.
 	Desc     protoreflect.EnumDescriptor // Desc is the enum descriptor.
 	Options  *EnumOptions                // Options are the enum options.
+	Features *Features                   // Features are the resolved editions features of the enum.
+	Parent   Element                     // Parent is the parent file or message.
 	Values   []*EnumValue                // Values are the values defined in the enum.
+	Location Location                    // Location is the location of the enum in the source file.
 	Comments protogen.CommentSet         // Comments are the comments associated with the enum.
 }
 
@@ -41,6 +47,7 @@ type EnumValue struct {
 	Desc     protoreflect.EnumValueDescriptor // Desc is the enum value descriptor.
 	Options  *EnumValueOptions                // Options are the enum value options.
 	Parent   *Enum                            // Parent is the parent enum.
+	Location Location                         // Location is the location of the enum value in the source file.
 	Comments protogen.CommentSet              // Comments are the comments associated with the enum value.
 }
 
@@ -56,6 +63,7 @@ type Service struct {
 	Options  *ServiceOptions                // Options are the service options.
 	Parent   *File                          // Parent is the parent file.
 	Methods  []*Method                      // Methods are the methods defined in the service.
+	Location Location                       // Location is the location of the service in the source file.
 	Comments protogen.CommentSet            // Comments are the comments associated with the service.
 }
 
@@ -72,6 +80,7 @@ type Method struct {
 	Parent   *Service                      // Parent is the parent service.
 	Input    *Message                      // Input is the input message of the method.
 	Output   *Message                      // Output is the output message of the method.
+	Location Location                      // Location is the location of the method in the source file.
 	Comments protogen.CommentSet           // Comments are the comments associated with the method.
 }
 
@@ -83,14 +92,22 @@ type MethodOptions struct {
 
 // Message represents a protobuf message descriptor.
 type Message struct {
-	FullName protoreflect.FullName          // FullName is the full name of the message.
-	Desc     protoreflect.MessageDescriptor // Desc is the message descriptor.
-	Options  *MessageOptions                // Options are the message options.
-	Fields   []*Field                       // Fields are the fields defined in the message.
-	Oneofs   []*Oneof                       // Oneofs are the oneof fields defined in the message.
-	Enums    []*Enum                        // Enums are the enums defined in the message.
-	Messages []*Message                     // Messages are the nested messages defined in the message.
-	Comments protogen.CommentSet            // Comments are the comments associated with the message.
+	FullName        protoreflect.FullName          // FullName is the full name of the message.
+	Desc            protoreflect.MessageDescriptor // Desc is the message descriptor.
+	Options         *MessageOptions                // Options are the message options.
+	Features        *Features                      // Features are the resolved editions features of the message.
+	Parent          Element                        // Parent is the parent file or message.
+	IsMapEntry      bool                           // IsMapEntry reports whether the message is a map entry message generated for a map field.
+	Fields          []*Field                       // Fields are the fields defined in the message.
+	Oneofs          []*Oneof                       // Oneofs are the oneof fields defined in the message, excluding synthetic ones.
+	SyntheticOneofs []*Oneof                       // SyntheticOneofs are the synthetic oneofs generated for proto3 optional fields in the message.
+	Enums           []*Enum                        // Enums are the enums defined in the message.
+	Messages        []*Message                     // Messages are the nested messages defined in the message, excluding map entry messages.
+	MapEntries      []*Message                     // MapEntries are the map entry messages generated for the map fields of the message.
+	Extensions      []*Field                       // Extensions are the extension fields declared in the scope of the message.
+	ExtendedBy      []*Field                       // ExtendedBy are the extension fields, declared anywhere in the request, that extend the message.
+	Location        Location                       // Location is the location of the message in the source file.
+	Comments        protogen.CommentSet            // Comments are the comments associated with the message.
 }
 
 // MessageOptions represents the options for a protobuf message.
@@ -100,12 +117,15 @@ type MessageOptions struct {
 
 // Oneof represents a oneof field in a protobuf message.
 type Oneof struct {
-	FullName protoreflect.FullName        // FullName is the full name of the oneof field.
-	Desc     protoreflect.OneofDescriptor // Desc is the oneof field descriptor.
-	Options  *OneofOptions                // Options are the oneof field options.
-	Parent   *Message                     // Parent is the parent message.
-	Fields   []*Field                     // Fields are the fields defined in the oneof.
-	Comments protogen.CommentSet          // Comments are the comments associated with the oneof field.
+	FullName    protoreflect.FullName        // FullName is the full name of the oneof field.
+	Desc        protoreflect.OneofDescriptor // Desc is the oneof field descriptor.
+	Options     *OneofOptions                // Options are the oneof field options.
+	Features    *Features                    // Features are the resolved editions features of the oneof field.
+	Parent      *Message                     // Parent is the parent message.
+	IsSynthetic bool                         // IsSynthetic reports whether the oneof is generated for a proto3 optional field rather than declared in the source.
+	Fields      []*Field                     // Fields are the fields defined in the oneof.
+	Location    Location                     // Location is the location of the oneof field in the source file.
+	Comments    protogen.CommentSet          // Comments are the comments associated with the oneof field.
 }
 
 // OneofOptions represents the options for a protobuf oneof field.
@@ -118,11 +138,19 @@ type Field struct {
 	FullName protoreflect.FullName        // FullName is the full name of the field.
 	Desc     protoreflect.FieldDescriptor // Desc is the field descriptor.
 	Options  *FieldOptions                // Options are the field options.
-	Parent   *Message                     // Parent is the parent message.
+	Features *Features                    // Features are the resolved editions features of the field.
+	Parent   *Message                     // Parent is the parent message, or nil if this is an extension field declared at the top level of a file.
 	Extendee *Message                     // Extendee is the extended message, if this is an extension field.
 	Enum     *Enum                        // Enum is the enum type of the field, if any.
-	Message  *Message                     // Message is the message type of the field, if any.
+	Message  *Message                     // Message is the message type of the field, if any. For a map field, it is the map entry message.
+	IsMap    bool                         // IsMap reports whether the field is a map field.
+	MapKey   *Field                       // MapKey is the key field of the map entry message, if this is a map field.
+	MapValue *Field                       // MapValue is the value field of the map entry message, if this is a map field.
+	Oneof    *Oneof                       // Oneof is the oneof containing the field, if any, including synthetic one.
+	Location Location                     // Location is the location of the field in the source file.
 	Comments protogen.CommentSet          // Comments are the comments associated with the field.
+
+	file *File
 }
 
 // FieldOptions represents the options for a protobuf field.
@@ -0,0 +1,158 @@
+package protocplugin
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// ErrNotFound is returned when a looked up element does not exist in a registry.
+var ErrNotFound = errors.New("not found")
+
+// Registry is a collection of the elements of all the files in a request, including dependencies.
+// Elements can be looked up by their full names and iterated over in declaration order.
+type Registry struct {
+	files    []*File
+	messages []*Message
+	enums    []*Enum
+	services []*Service
+	methods  []*Method
+	fields   []*Field
+
+	filesByPath    map[string]*File
+	messagesByName map[protoreflect.FullName]*Message
+	enumsByName    map[protoreflect.FullName]*Enum
+	servicesByName map[protoreflect.FullName]*Service
+	methodsByName  map[protoreflect.FullName]*Method
+	fieldsByName   map[protoreflect.FullName]*Field
+}
+
+func newRegistry() *Registry {
+	return &Registry{
+		filesByPath:    map[string]*File{},
+		messagesByName: map[protoreflect.FullName]*Message{},
+		enumsByName:    map[protoreflect.FullName]*Enum{},
+		servicesByName: map[protoreflect.FullName]*Service{},
+		methodsByName:  map[protoreflect.FullName]*Method{},
+		fieldsByName:   map[protoreflect.FullName]*Field{},
+	}
+}
+
+func (r *Registry) addFile(f *File) {
+	r.files = append(r.files, f)
+	r.filesByPath[f.Desc.Path()] = f
+}
+
+func (r *Registry) addMessage(m *Message) {
+	r.messages = append(r.messages, m)
+	r.messagesByName[m.FullName] = m
+}
+
+func (r *Registry) addEnum(e *Enum) {
+	r.enums = append(r.enums, e)
+	r.enumsByName[e.FullName] = e
+}
+
+func (r *Registry) addService(s *Service) {
+	r.services = append(r.services, s)
+	r.servicesByName[s.FullName] = s
+}
+
+func (r *Registry) addMethod(m *Method) {
+	r.methods = append(r.methods, m)
+	r.methodsByName[m.FullName] = m
+}
+
+func (r *Registry) addField(f *Field) {
+	r.fields = append(r.fields, f)
+	r.fieldsByName[f.FullName] = f
+}
+
+// Files returns all the files in topological order, so each file appears before any file that imports it.
+func (r *Registry) Files() []*File {
+	return r.files
+}
+
+// Messages returns all the messages in declaration order, including nested ones.
+func (r *Registry) Messages() []*Message {
+	return r.messages
+}
+
+// Enums returns all the enums in declaration order, including nested ones.
+func (r *Registry) Enums() []*Enum {
+	return r.enums
+}
+
+// Services returns all the services in declaration order.
+func (r *Registry) Services() []*Service {
+	return r.services
+}
+
+// Methods returns all the methods in declaration order.
+func (r *Registry) Methods() []*Method {
+	return r.methods
+}
+
+// Fields returns all the fields in declaration order, including extension fields.
+func (r *Registry) Fields() []*Field {
+	return r.fields
+}
+
+// File returns the file with the given path.
+func (r *Registry) File(path string) (*File, error) {
+	if f, ok := r.filesByPath[path]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("file %q: %w", path, ErrNotFound)
+}
+
+// Message returns the message with the given full name.
+func (r *Registry) Message(name protoreflect.FullName) (*Message, error) {
+	if m, ok := r.messagesByName[name]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("message %q: %w", name, ErrNotFound)
+}
+
+// MessageByTypeURL returns the message identified by the given type URL such as the one in google.protobuf.Any.
+// The full name of the message is taken from the part of the URL after the last '/'.
+func (r *Registry) MessageByTypeURL(url string) (*Message, error) {
+	name := url
+	if i := strings.LastIndexByte(url, '/'); i >= 0 {
+		name = url[i+1:]
+	}
+	return r.Message(protoreflect.FullName(name))
+}
+
+// Enum returns the enum with the given full name.
+func (r *Registry) Enum(name protoreflect.FullName) (*Enum, error) {
+	if e, ok := r.enumsByName[name]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("enum %q: %w", name, ErrNotFound)
+}
+
+// Service returns the service with the given full name.
+func (r *Registry) Service(name protoreflect.FullName) (*Service, error) {
+	if s, ok := r.servicesByName[name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("service %q: %w", name, ErrNotFound)
+}
+
+// Method returns the method with the given full name.
+func (r *Registry) Method(name protoreflect.FullName) (*Method, error) {
+	if m, ok := r.methodsByName[name]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("method %q: %w", name, ErrNotFound)
+}
+
+// Field returns the field or extension field with the given full name.
+func (r *Registry) Field(name protoreflect.FullName) (*Field, error) {
+	if f, ok := r.fieldsByName[name]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("field %q: %w", name, ErrNotFound)
+}
@@ -0,0 +1,77 @@
+package protocplugin_test
+
+import (
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		main := files["main.proto"]
+		request := main.Messages[0]
+
+		t.Run("lookup", func(t *testing.T) {
+			m, err := registry.Message("main.Request")
+			require.NoError(t, err)
+			assert.Same(t, request, m)
+
+			m, err = registry.Message("main.Request.Inner")
+			require.NoError(t, err)
+			assert.Same(t, request.Messages[0], m)
+
+			m, err = registry.MessageByTypeURL("type.googleapis.com/dep.Shared")
+			require.NoError(t, err)
+			assert.Same(t, files["dep.proto"].Messages[0], m)
+
+			e, err := registry.Enum("dep.Kind")
+			require.NoError(t, err)
+			assert.Same(t, files["dep.proto"].Enums[0], e)
+
+			s, err := registry.Service("main.Service")
+			require.NoError(t, err)
+			assert.Same(t, main.Services[0], s)
+
+			mt, err := registry.Method("main.Service.Call")
+			require.NoError(t, err)
+			assert.Same(t, main.Services[0].Methods[0], mt)
+
+			f, err := registry.Field("main.Request.kind")
+			require.NoError(t, err)
+			assert.Same(t, request.Fields[1], f)
+
+			file, err := registry.File("dep.proto")
+			require.NoError(t, err)
+			assert.Same(t, files["dep.proto"], file)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			_, err := registry.Message("main.Unknown")
+			assert.ErrorIs(t, err, protocplugin.ErrNotFound)
+			assert.ErrorContains(t, err, `message "main.Unknown"`)
+
+			_, err = registry.Enum("main.Request")
+			assert.ErrorIs(t, err, protocplugin.ErrNotFound)
+		})
+
+		t.Run("declaration order", func(t *testing.T) {
+			var names []protoreflect.FullName
+			for _, m := range registry.Messages() {
+				names = append(names, m.FullName)
+			}
+			assert.Equal(t, []protoreflect.FullName{"dep.Shared", "main.Request", "main.Request.Inner", "main.Response"}, names)
+
+			names = nil
+			for _, f := range registry.Fields() {
+				names = append(names, f.FullName)
+			}
+			assert.Equal(t, []protoreflect.FullName{"main.Request.shared", "main.Request.kind", "main.Request.inner", "main.Request.id"}, names)
+		})
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileDep, testFileMain)
+	assert.Empty(t, resp.GetError())
+}
@@ -0,0 +1,87 @@
+package protocplugin
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+	"io"
+	"os"
+)
+
+// DumpRequestEnv is the environment variable specifying the file to which Run writes the request.
+// The parameter "dump_request" takes precedence over it.
+const DumpRequestEnv = "PROTOC_PLUGIN_DUMP_REQUEST"
+
+// dumpRequest writes the raw request into the file specified by the parameter "dump_request" or the environment variable DumpRequestEnv if any.
+func dumpRequest(parameter string, raw []byte) error {
+	path := libraryString(parameter, "dump_request")
+	if path == "" {
+		path = os.Getenv(DumpRequestEnv)
+	}
+	if path == "" {
+		return nil
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return fmt.Errorf("failed to dump request: %w", err)
+	}
+	return nil
+}
+
+// ReplayFormat represents the format of the response printed by Replay.
+type ReplayFormat int
+
+const (
+	ReplayText ReplayFormat = iota // ReplayText prints the response in the protobuf text format.
+	ReplayJSON                     // ReplayJSON prints the response in the protobuf JSON format.
+)
+
+// Replay executes the plugin handler with the request dumped by Run, and prints the response in the given format to out.
+// It is intended to reproduce the behavior of the plugin under protoc, e.g. in a debugger.
+// The request is not dumped again even if it has the parameter "dump_request".
+func Replay(in io.Reader, out io.Writer, handle PluginHandler, format ReplayFormat, opts ...Option) error {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	inBuf, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{}
+	err = proto.Unmarshal(inBuf, req)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+
+	resp, err := generate(context.Background(), req, func(ctx context.Context, req *pluginpb.CodeGeneratorRequest, files map[string]*File, registry *Registry, diags *Diagnostics) ([]*GeneratedFile, error) {
+		return handle(req, files, registry, diags)
+	}, cfg)
+	if err != nil {
+		return err
+	}
+
+	var outBuf []byte
+	switch format {
+	case ReplayText:
+		outBuf, err = prototext.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(resp)
+	case ReplayJSON:
+		outBuf, err = protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(resp)
+	default:
+		return fmt.Errorf("unknown replay format: %d", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+
+	_, err = out.Write(outBuf)
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package protocplugin_test
+
+import (
+	"bytes"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// generateParameter returns a handler which generates a file containing the parameter.
+func generateParameter(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+	return []*protocplugin.GeneratedFile{{Name: "parameter.txt", Content: req.GetParameter()}}, nil
+}
+
+func TestRun_DumpRequest(t *testing.T) {
+	t.Run("parameter", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "request.binpb")
+		req := newRequest(t, "dump_request="+path, testFileDep)
+
+		resp := runRequest(t, req, generateParameter)
+
+		require.Nil(t, resp.Error)
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		dumped := &pluginpb.CodeGeneratorRequest{}
+		require.NoError(t, proto.Unmarshal(b, dumped))
+		assert.True(t, proto.Equal(req, dumped))
+	})
+	t.Run("environment variable", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "request.binpb")
+		t.Setenv(protocplugin.DumpRequestEnv, path)
+		req := newRequest(t, "", testFileDep)
+
+		resp := runRequest(t, req, generateParameter)
+
+		require.Nil(t, resp.Error)
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		dumped := &pluginpb.CodeGeneratorRequest{}
+		require.NoError(t, proto.Unmarshal(b, dumped))
+		assert.True(t, proto.Equal(req, dumped))
+	})
+	t.Run("not dumped", func(t *testing.T) {
+		t.Setenv(protocplugin.DumpRequestEnv, "")
+
+		resp := runRequest(t, newRequest(t, "", testFileDep), generateParameter)
+
+		require.Nil(t, resp.Error)
+	})
+}
+
+func TestRun_DumpRequest_Error(t *testing.T) {
+	req := newRequest(t, "dump_request="+filepath.Join(t.TempDir(), "no", "such", "dir"), testFileDep)
+	in, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	err = protocplugin.Run(bytes.NewReader(in), &bytes.Buffer{}, generateParameter)
+
+	assert.ErrorContains(t, err, "failed to dump request: ")
+}
+
+func TestReplay(t *testing.T) {
+	req := newRequest(t, "a=b,dump_request=request.binpb", testFileDep)
+	in, err := proto.Marshal(req)
+	require.NoError(t, err)
+	want := &pluginpb.CodeGeneratorResponse{
+		File: []*pluginpb.CodeGeneratorResponse_File{{Name: proto.String("parameter.txt"), Content: proto.String("a=b,dump_request=request.binpb")}},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		out := bytes.Buffer{}
+		require.NoError(t, protocplugin.Replay(bytes.NewReader(in), &out, generateParameter, protocplugin.ReplayText))
+
+		got := &pluginpb.CodeGeneratorResponse{}
+		require.NoError(t, prototext.Unmarshal(out.Bytes(), got))
+		assert.True(t, proto.Equal(want, got), "got: %s", out.String())
+	})
+	t.Run("json", func(t *testing.T) {
+		out := bytes.Buffer{}
+		require.NoError(t, protocplugin.Replay(bytes.NewReader(in), &out, generateParameter, protocplugin.ReplayJSON))
+
+		got := &pluginpb.CodeGeneratorResponse{}
+		require.NoError(t, protojson.Unmarshal(out.Bytes(), got))
+		assert.True(t, proto.Equal(want, got), "got: %s", out.String())
+	})
+	t.Run("unknown format", func(t *testing.T) {
+		err := protocplugin.Replay(bytes.NewReader(in), &bytes.Buffer{}, generateParameter, protocplugin.ReplayFormat(-1))
+
+		assert.EqualError(t, err, "unknown replay format: -1")
+	})
+	_, err = os.Stat("request.binpb")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
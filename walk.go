@@ -0,0 +1,134 @@
+package protocplugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// SkipChildren is used as a return value from the Enter methods of Visitor to indicate that the children of the element are to be skipped.
+	SkipChildren = errors.New("skip children")
+	// SkipAll is used as a return value from the methods of Visitor to indicate that all the remaining elements are to be skipped.
+	SkipAll = errors.New("skip all")
+)
+
+// Visitor is the interface of the callbacks invoked by Walk for each kind of element.
+// EnterXxx is invoked before the children of an element are visited, and LeaveXxx is invoked after them.
+// If EnterXxx returns SkipChildren, the children are skipped but LeaveXxx is still invoked.
+// If any method returns SkipAll, Walk stops and returns nil.
+// If any method returns another non-nil error, Walk stops and returns the error.
+type Visitor interface {
+	EnterFile(f *File) error
+	LeaveFile(f *File) error
+	EnterMessage(m *Message) error
+	LeaveMessage(m *Message) error
+	EnterField(f *Field) error
+	LeaveField(f *Field) error
+	EnterOneof(o *Oneof) error
+	LeaveOneof(o *Oneof) error
+	EnterEnum(e *Enum) error
+	LeaveEnum(e *Enum) error
+	EnterEnumValue(v *EnumValue) error
+	LeaveEnumValue(v *EnumValue) error
+	EnterService(s *Service) error
+	LeaveService(s *Service) error
+	EnterMethod(m *Method) error
+	LeaveMethod(m *Method) error
+}
+
+// BaseVisitor is a Visitor whose methods do nothing.
+// It is intended to be embedded in a visitor which implements only the methods it needs.
+type BaseVisitor struct{}
+
+var _ Visitor = BaseVisitor{}
+
+func (BaseVisitor) EnterFile(*File) error           { return nil }
+func (BaseVisitor) LeaveFile(*File) error           { return nil }
+func (BaseVisitor) EnterMessage(*Message) error     { return nil }
+func (BaseVisitor) LeaveMessage(*Message) error     { return nil }
+func (BaseVisitor) EnterField(*Field) error         { return nil }
+func (BaseVisitor) LeaveField(*Field) error         { return nil }
+func (BaseVisitor) EnterOneof(*Oneof) error         { return nil }
+func (BaseVisitor) LeaveOneof(*Oneof) error         { return nil }
+func (BaseVisitor) EnterEnum(*Enum) error           { return nil }
+func (BaseVisitor) LeaveEnum(*Enum) error           { return nil }
+func (BaseVisitor) EnterEnumValue(*EnumValue) error { return nil }
+func (BaseVisitor) LeaveEnumValue(*EnumValue) error { return nil }
+func (BaseVisitor) EnterService(*Service) error     { return nil }
+func (BaseVisitor) LeaveService(*Service) error     { return nil }
+func (BaseVisitor) EnterMethod(*Method) error       { return nil }
+func (BaseVisitor) LeaveMethod(*Method) error       { return nil }
+
+// Walk traverses the given element and its descendants in depth-first order, invoking the methods of the visitor.
+// The children are visited in the following order:
+//   - File: Enums, Messages, Extensions and Services.
+//   - Message: Fields, Oneofs, Enums, Messages and Extensions.
+//   - Enum: Values.
+//   - Service: Methods.
+//
+// Fields in a oneof are visited as children of the message, not of the oneof.
+// Synthetic oneofs and map entry messages are not visited.
+func Walk(e Element, v Visitor) error {
+	if err := walk(e, v); err != nil && err != SkipAll {
+		return err
+	}
+	return nil
+}
+
+func walk(e Element, v Visitor) error {
+	var enter, leave func() error
+	children := []Element{}
+	switch e := e.(type) {
+	default:
+		return fmt.Errorf("unsupported element type: %T", e)
+	case *File:
+		enter, leave = func() error { return v.EnterFile(e) }, func() error { return v.LeaveFile(e) }
+		children = appendElements(children, e.Enums)
+		children = appendElements(children, e.Messages)
+		children = appendElements(children, e.Extensions)
+		children = appendElements(children, e.Services)
+	case *Message:
+		enter, leave = func() error { return v.EnterMessage(e) }, func() error { return v.LeaveMessage(e) }
+		children = appendElements(children, e.Fields)
+		children = appendElements(children, e.Oneofs)
+		children = appendElements(children, e.Enums)
+		children = appendElements(children, e.Messages)
+		children = appendElements(children, e.Extensions)
+	case *Field:
+		enter, leave = func() error { return v.EnterField(e) }, func() error { return v.LeaveField(e) }
+	case *Oneof:
+		enter, leave = func() error { return v.EnterOneof(e) }, func() error { return v.LeaveOneof(e) }
+	case *Enum:
+		enter, leave = func() error { return v.EnterEnum(e) }, func() error { return v.LeaveEnum(e) }
+		children = appendElements(children, e.Values)
+	case *EnumValue:
+		enter, leave = func() error { return v.EnterEnumValue(e) }, func() error { return v.LeaveEnumValue(e) }
+	case *Service:
+		enter, leave = func() error { return v.EnterService(e) }, func() error { return v.LeaveService(e) }
+		children = appendElements(children, e.Methods)
+	case *Method:
+		enter, leave = func() error { return v.EnterMethod(e) }, func() error { return v.LeaveMethod(e) }
+	}
+
+	if err := enter(); err == SkipChildren {
+		children = nil
+	} else if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := walk(child, v); err != nil {
+			return err
+		}
+	}
+	if err := leave(); err != nil && err != SkipChildren {
+		return err
+	}
+	return nil
+}
+
+func appendElements[T Element](dst []Element, src []T) []Element {
+	for _, e := range src {
+		dst = append(dst, e)
+	}
+	return dst
+}
@@ -0,0 +1,164 @@
+package protocplugin_test
+
+import (
+	"fmt"
+	protocplugin "github.com/Jumpaku/protoc-plugin-lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+type recordingVisitor struct {
+	protocplugin.BaseVisitor
+	events []string
+	skip   protoreflect.FullName
+	stop   protoreflect.FullName
+}
+
+func (v *recordingVisitor) enter(name protoreflect.FullName) error {
+	v.events = append(v.events, "enter "+string(name))
+	switch name {
+	case v.skip:
+		return protocplugin.SkipChildren
+	case v.stop:
+		return protocplugin.SkipAll
+	}
+	return nil
+}
+
+func (v *recordingVisitor) leave(name protoreflect.FullName) error {
+	v.events = append(v.events, "leave "+string(name))
+	return nil
+}
+
+func (v *recordingVisitor) EnterFile(f *protocplugin.File) error {
+	return v.enter(protoreflect.FullName(f.Desc.Path()))
+}
+func (v *recordingVisitor) LeaveFile(f *protocplugin.File) error {
+	return v.leave(protoreflect.FullName(f.Desc.Path()))
+}
+func (v *recordingVisitor) EnterMessage(m *protocplugin.Message) error { return v.enter(m.FullName) }
+func (v *recordingVisitor) LeaveMessage(m *protocplugin.Message) error { return v.leave(m.FullName) }
+func (v *recordingVisitor) EnterField(f *protocplugin.Field) error     { return v.enter(f.FullName) }
+func (v *recordingVisitor) LeaveField(f *protocplugin.Field) error     { return v.leave(f.FullName) }
+func (v *recordingVisitor) EnterOneof(o *protocplugin.Oneof) error     { return v.enter(o.FullName) }
+func (v *recordingVisitor) LeaveOneof(o *protocplugin.Oneof) error     { return v.leave(o.FullName) }
+func (v *recordingVisitor) EnterService(s *protocplugin.Service) error { return v.enter(s.FullName) }
+func (v *recordingVisitor) LeaveService(s *protocplugin.Service) error { return v.leave(s.FullName) }
+func (v *recordingVisitor) EnterMethod(m *protocplugin.Method) error   { return v.enter(m.FullName) }
+func (v *recordingVisitor) LeaveMethod(m *protocplugin.Method) error   { return v.leave(m.FullName) }
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		skip protoreflect.FullName
+		stop protoreflect.FullName
+		want []string
+	}{
+		{
+			name: "all",
+			file: "main.proto",
+			want: []string{
+				"enter main.proto",
+				"enter main.Request",
+				"enter main.Request.shared", "leave main.Request.shared",
+				"enter main.Request.kind", "leave main.Request.kind",
+				"enter main.Request.inner", "leave main.Request.inner",
+				"enter main.Request.id", "leave main.Request.id",
+				"enter main.Request.key", "leave main.Request.key",
+				"enter main.Request.Inner", "leave main.Request.Inner",
+				"leave main.Request",
+				"enter main.Response", "leave main.Response",
+				"enter main.Service",
+				"enter main.Service.Call", "leave main.Service.Call",
+				"leave main.Service",
+				"leave main.proto",
+			},
+		},
+		{
+			name: "skip children",
+			file: "main.proto",
+			skip: "main.Request",
+			want: []string{
+				"enter main.proto",
+				"enter main.Request", "leave main.Request",
+				"enter main.Response", "leave main.Response",
+				"enter main.Service",
+				"enter main.Service.Call", "leave main.Service.Call",
+				"leave main.Service",
+				"leave main.proto",
+			},
+		},
+		{
+			name: "skip all",
+			file: "main.proto",
+			stop: "main.Request.inner",
+			want: []string{
+				"enter main.proto",
+				"enter main.Request",
+				"enter main.Request.shared", "leave main.Request.shared",
+				"enter main.Request.kind", "leave main.Request.kind",
+				"enter main.Request.inner",
+			},
+		},
+		{
+			name: "extensions",
+			file: "ext.proto",
+			want: []string{
+				"enter ext.proto",
+				"enter ext.Base",
+				"enter ext.Base.nested_ext", "leave ext.Base.nested_ext",
+				"leave ext.Base",
+				"enter ext.top_ext", "leave ext.top_ext",
+				"leave ext.proto",
+			},
+		},
+		{
+			name: "maps and synthetic oneofs are not visited",
+			file: "map.proto",
+			stop: "maps.Value",
+			want: []string{
+				"enter map.proto",
+				"enter maps.Message",
+				"enter maps.Message.kinds", "leave maps.Message.kinds",
+				"enter maps.Message.values", "leave maps.Message.values",
+				"enter maps.Message.Nested", "leave maps.Message.Nested",
+				"leave maps.Message",
+				"enter maps.Value",
+			},
+		},
+	}
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				v := &recordingVisitor{skip: tt.skip, stop: tt.stop}
+				require.NoError(t, protocplugin.Walk(files[tt.file], v))
+				assert.Equal(t, tt.want, v.events)
+			})
+		}
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileDep, testFileMain, testFileExt, testFileMap)
+	assert.Empty(t, resp.GetError())
+}
+
+type failingVisitor struct {
+	protocplugin.BaseVisitor
+}
+
+func (failingVisitor) EnterMethod(m *protocplugin.Method) error {
+	return fmt.Errorf("unexpected method %s", m.FullName)
+}
+
+func TestWalk_Error(t *testing.T) {
+	handle := func(req *pluginpb.CodeGeneratorRequest, files map[string]*protocplugin.File, registry *protocplugin.Registry, diags *protocplugin.Diagnostics) ([]*protocplugin.GeneratedFile, error) {
+		err := protocplugin.Walk(files["main.proto"], failingVisitor{})
+		assert.EqualError(t, err, "unexpected method main.Service.Call")
+		return nil, nil
+	}
+	resp := runPlugin(t, handle, testFileDep, testFileMain)
+	assert.Empty(t, resp.GetError())
+}